@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/kyleterry/tenyks/pkg/adapter"
 	"github.com/kyleterry/tenyks/pkg/adapter/irc"
@@ -42,17 +43,66 @@ func main() {
 		case adapter.AdapterTypeIRC:
 			ircConfig := sc.Config.(config.IRCServerConfig)
 
+			rejoinPolicies := map[string]irc.RejoinPolicy{}
+			for channel, policy := range ircConfig.ChannelRejoinPolicies {
+				rejoinPolicies[channel] = irc.RejoinPolicy(policy)
+			}
+
+			channels := []string{}
+			channelKeys := map[string]string{}
+
+			for _, channel := range ircConfig.Channels {
+				channels = append(channels, channel.Name)
+
+				if channel.Key != "" {
+					channelKeys[channel.Name] = channel.Key
+				}
+
+				if channel.RejoinPolicy != "" {
+					rejoinPolicies[channel.Name] = irc.RejoinPolicy(channel.RejoinPolicy)
+				}
+			}
+
 			c, err := irc.New(irc.Config{
-				Name:     ircConfig.Name,
-				Server:   ircConfig.ServerAddr,
-				UseTLS:   ircConfig.UseTLS,
-				Password: ircConfig.Password,
-				User:     ircConfig.User,
-				RealName: ircConfig.RealName,
-				Nicks:    ircConfig.Nicks,
-				Channels: ircConfig.Channels,
-				Commands: ircConfig.Commands,
-				Logger:   standardLogger,
+				Name:                  ircConfig.Name,
+				Server:                ircConfig.ServerAddr,
+				UseTLS:                ircConfig.UseTLS,
+				Password:              ircConfig.Password,
+				User:                  ircConfig.User,
+				RealName:              ircConfig.RealName,
+				Nicks:                 ircConfig.Nicks,
+				Channels:              channels,
+				ChannelKeys:           channelKeys,
+				Commands:              ircConfig.Commands,
+				Capabilities:          ircConfig.Capabilities,
+				NickRegainInterval:    time.Duration(ircConfig.NickRegainInterval),
+				PingInterval:          time.Duration(ircConfig.PingInterval),
+				PingTimeout:           time.Duration(ircConfig.PingTimeout),
+				SASLMechanism:         ircConfig.SASLMechanism,
+				SASLAccount:           ircConfig.SASLAccount,
+				SASLPassword:          ircConfig.SASLPassword,
+				SASLTimeout:           time.Duration(ircConfig.SASLTimeout),
+				RootCAPath:            ircConfig.RootCAPath,
+				ClientCertPath:        ircConfig.ClientCert,
+				ClientKeyPath:         ircConfig.ClientKey,
+				TLSServerName:         ircConfig.TLSServerName,
+				TLSInsecureSkipVerify: ircConfig.TLSInsecureSkipVerify,
+				FloodBurst:            ircConfig.FloodBurst,
+				FloodRate:             time.Duration(ircConfig.FloodRate),
+				FloodPenaltyBytes:     ircConfig.FloodPenaltyBytes,
+				FloodPerTarget:        ircConfig.FloodPerTarget,
+				FloodTargetBurst:      ircConfig.FloodTargetBurst,
+				FloodTargetRate:       time.Duration(ircConfig.FloodTargetRate),
+				FloodMaxDelay:         time.Duration(ircConfig.FloodMaxDelay),
+				RejoinPolicy:          irc.RejoinPolicy(ircConfig.RejoinPolicy),
+				ChannelRejoinPolicies: rejoinPolicies,
+				RejoinDelay:           time.Duration(ircConfig.RejoinDelay),
+				RejoinMaxDelay:        time.Duration(ircConfig.RejoinMaxDelay),
+				CTCPVersion:           ircConfig.CTCPVersion,
+				CTCPIgnore:            ircConfig.CTCPIgnore,
+				CTCPReplyRate:         time.Duration(ircConfig.CTCPReplyRate),
+				InviteAllowlist:       ircConfig.InviteAllowlist,
+				Logger:                standardLogger,
 			})
 
 			if err != nil {
@@ -0,0 +1,331 @@
+package irc
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/kyleterry/tenyks/pkg/logger"
+)
+
+// DefaultCapabilities are requested on every connection if the server
+// advertises them. Capabilities from Config.Capabilities are requested in
+// addition to these.
+var DefaultCapabilities = []string{
+	"cap-notify",
+	"multi-prefix",
+	"account-notify",
+	"account-tag",
+	"away-notify",
+	"chghost",
+	"extended-join",
+	"message-tags",
+	"server-time",
+	"echo-message",
+	"labeled-response",
+}
+
+// Capability is an IRCv3 capability advertised by the server. Value is only
+// set for capabilities that have one, such as `sasl=PLAIN,EXTERNAL`.
+type Capability struct {
+	Name  string
+	Value string
+}
+
+// capabilityNegotiator tracks the state of IRCv3 capability negotiation for
+// a connection. It's only read and written from the dispatcher goroutine or
+// while holding the connection lock.
+type capabilityNegotiator struct {
+	// wanted are the capabilities we will request if the server has them
+	wanted []string
+	// available are the capabilities the server advertised in CAP LS and
+	// CAP NEW
+	available map[string]string
+	// pending are the capabilities we requested and haven't heard back about
+	pending map[string]bool
+	// negotiating is true between CAP LS and CAP END during registration
+	negotiating bool
+	// holds is the number of features that need CAP END to be delayed (SASL,
+	// for example).
+	holds int
+}
+
+func newCapabilityNegotiator(wanted []string) *capabilityNegotiator {
+	seen := map[string]bool{}
+	caps := []string{}
+
+	for _, name := range append(append([]string{}, DefaultCapabilities...), wanted...) {
+		if name == "" || seen[name] {
+			continue
+		}
+
+		seen[name] = true
+		caps = append(caps, name)
+	}
+
+	return &capabilityNegotiator{
+		wanted:    caps,
+		available: map[string]string{},
+		pending:   map[string]bool{},
+	}
+}
+
+func (cn *capabilityNegotiator) reset() {
+	cn.available = map[string]string{}
+	cn.pending = map[string]bool{}
+	cn.negotiating = false
+	cn.holds = 0
+}
+
+// want adds a capability to the list of capabilities to request.
+func (cn *capabilityNegotiator) want(name string) {
+	for _, w := range cn.wanted {
+		if w == name {
+			return
+		}
+	}
+
+	cn.wanted = append(cn.wanted, name)
+}
+
+// requestable returns the wanted capabilities that are available and haven't
+// been requested or enabled yet.
+func (cn *capabilityNegotiator) requestable(enabled map[string]string) []string {
+	caps := []string{}
+
+	for _, name := range cn.wanted {
+		if _, ok := cn.available[name]; !ok {
+			continue
+		}
+
+		if _, ok := enabled[name]; ok {
+			continue
+		}
+
+		if cn.pending[name] {
+			continue
+		}
+
+		caps = append(caps, name)
+	}
+
+	return caps
+}
+
+// HasCapability returns true if the server acknowledged our request for the
+// named capability and it hasn't been removed since.
+func (c *Connection) HasCapability(name string) bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	_, ok := c.Status.Capabilities[name]
+
+	return ok
+}
+
+// HoldRegistration delays CAP END, and therefore the end of registration,
+// until ReleaseRegistration is called. It's used by features that need to
+// talk to the server after capabilities are acknowledged but before
+// registration completes, like SASL. It must be called from an OnCapability
+// hook for the hold to be in place before negotiation is finished.
+func (c *Connection) HoldRegistration() {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.caps.negotiating {
+		c.caps.holds++
+	}
+}
+
+// ReleaseRegistration releases a hold placed with HoldRegistration and ends
+// capability negotiation if nothing else is holding it.
+func (c *Connection) ReleaseRegistration(ctx context.Context) error {
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		if conn.caps.holds > 0 {
+			conn.caps.holds--
+		}
+	})
+
+	return c.maybeEndCapabilityNegotiation(ctx)
+}
+
+func (c *Connection) maybeEndCapabilityNegotiation(ctx context.Context) error {
+	var end bool
+
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		if conn.caps.negotiating && len(conn.caps.pending) == 0 && conn.caps.holds == 0 {
+			conn.caps.negotiating = false
+			end = true
+		}
+	})
+
+	if !end {
+		return nil
+	}
+
+	return c.EnqueueCommand(NewCapCommand("END"))
+}
+
+func (c *Connection) requestCapabilities(ctx context.Context) error {
+	var caps []string
+
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		caps = conn.caps.requestable(conn.Status.Capabilities)
+
+		for _, name := range caps {
+			conn.caps.pending[name] = true
+		}
+	})
+
+	if len(caps) == 0 {
+		return nil
+	}
+
+	return c.EnqueueCommand(NewCapRequestCommand(caps...))
+}
+
+func (c *Connection) setCapability(ctx context.Context, name string, enabled bool) {
+	var value string
+
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		delete(conn.caps.pending, name)
+
+		if enabled {
+			value = conn.caps.available[name]
+			conn.Status.Capabilities[name] = value
+		} else {
+			delete(conn.Status.Capabilities, name)
+		}
+	})
+
+	for _, hook := range c.OnCapability {
+		if err := hook(ctx, c, Capability{Name: name, Value: value}, enabled); err != nil {
+			c.log.Error("capability hook failed",
+				logger.Param{Key: "capability", Value: name},
+				logger.Param{Key: "error", Value: err})
+		}
+	}
+}
+
+// defaultCapabilityFunc starts capability negotiation. It must run before the
+// login hook so the server knows to hold registration until CAP END.
+func defaultCapabilityFunc(ctx context.Context, c *Connection) error {
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		conn.caps.reset()
+		conn.caps.negotiating = true
+		conn.Status.Capabilities = map[string]string{}
+	})
+
+	return c.EnqueueCommand(NewCapCommand("LS", "302"))
+}
+
+// defaultCapabilityHandler reacts to CAP messages from the server, both
+// during registration and at runtime through cap-notify.
+func defaultCapabilityHandler(ctx context.Context, c *Connection, command Command) error {
+	cmd, ok := command.(*CapCommand)
+	if !ok {
+		return nil
+	}
+
+	caps := cmd.Capabilities()
+
+	switch cmd.Subcommand() {
+	case "LS":
+		c.WithWriteLock(ctx, func(conn *Connection) {
+			for name, value := range caps {
+				conn.caps.available[name] = value
+			}
+		})
+
+		if cmd.IsContinuation() {
+			return nil
+		}
+
+		if err := c.requestCapabilities(ctx); err != nil {
+			return err
+		}
+
+		return c.maybeEndCapabilityNegotiation(ctx)
+	case "ACK":
+		for _, name := range sortedCapabilityNames(caps) {
+			if strings.HasPrefix(name, "-") {
+				c.setCapability(ctx, name[1:], false)
+
+				continue
+			}
+
+			c.setCapability(ctx, name, true)
+		}
+
+		c.log.Debug("capabilities acknowledged", logger.Param{Key: "caps", Value: cmd.Message().Trail})
+
+		return c.maybeEndCapabilityNegotiation(ctx)
+	case "NAK":
+		c.WithWriteLock(ctx, func(conn *Connection) {
+			for name := range caps {
+				delete(conn.caps.pending, name)
+			}
+		})
+
+		c.log.Error("capabilities rejected", logger.Param{Key: "caps", Value: cmd.Message().Trail})
+
+		return c.maybeEndCapabilityNegotiation(ctx)
+	case "NEW":
+		c.WithWriteLock(ctx, func(conn *Connection) {
+			for name, value := range caps {
+				conn.caps.available[name] = value
+			}
+		})
+
+		return c.requestCapabilities(ctx)
+	case "DEL":
+		for _, name := range sortedCapabilityNames(caps) {
+			c.WithWriteLock(ctx, func(conn *Connection) {
+				delete(conn.caps.available, name)
+			})
+
+			if c.HasCapability(name) {
+				c.setCapability(ctx, name, false)
+			}
+		}
+	}
+
+	return nil
+}
+
+// capabilityRegistrationUpdater ends negotiation if registration completes
+// while we still think we're negotiating. This happens on servers that don't
+// support CAP at all.
+func capabilityRegistrationUpdater(ctx context.Context, c *Connection, reply Reply) error {
+	switch reply.(type) {
+	case *WelcomeReply:
+		c.WithWriteLock(ctx, func(conn *Connection) {
+			conn.caps.negotiating = false
+			conn.caps.holds = 0
+		})
+	}
+
+	return nil
+}
+
+func resetCapabilities(ctx context.Context, c *Connection) error {
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		conn.caps.reset()
+		conn.Status.Capabilities = map[string]string{}
+	})
+
+	return nil
+}
+
+// sortedCapabilityNames returns the capability names in caps in a stable
+// order so hooks fire predictably.
+func sortedCapabilityNames(caps map[string]string) []string {
+	names := make([]string, 0, len(caps))
+	for name := range caps {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
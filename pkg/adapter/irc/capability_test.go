@@ -0,0 +1,114 @@
+package irc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapCommand(t *testing.T) {
+	cases := []struct {
+		raw          string
+		subcommand   string
+		continuation bool
+		caps         map[string]string
+	}{
+		{
+			raw:          ":irc.example.com CAP * LS * :multi-prefix sasl=PLAIN,EXTERNAL",
+			subcommand:   "LS",
+			continuation: true,
+			caps:         map[string]string{"multi-prefix": "", "sasl": "PLAIN,EXTERNAL"},
+		},
+		{
+			raw:        ":irc.example.com CAP tenyks ACK :server-time -account-tag",
+			subcommand: "ACK",
+			caps:       map[string]string{"server-time": "", "-account-tag": ""},
+		},
+		{
+			raw:        ":irc.example.com CAP tenyks NEW batch",
+			subcommand: "NEW",
+			caps:       map[string]string{"batch": ""},
+		},
+	}
+
+	for _, c := range cases {
+		msg, err := ParseMessage(c.raw)
+		require.NoError(t, err)
+
+		cmd := CapCommand{m: msg}
+
+		require.NoError(t, cmd.Validate())
+		require.Equal(t, c.subcommand, cmd.Subcommand())
+		require.Equal(t, c.continuation, cmd.IsContinuation())
+		require.Equal(t, c.caps, cmd.Capabilities())
+	}
+}
+
+func TestCapabilityNegotiation(t *testing.T) {
+	ctx := context.Background()
+	c := newTestConnection(t, Config{Capabilities: []string{"server-time", "away-notify"}})
+
+	var enabled []string
+	c.OnCapability = append(c.OnCapability, func(_ context.Context, _ *Connection, cap Capability, on bool) error {
+		if on {
+			enabled = append(enabled, cap.Name)
+		}
+
+		return nil
+	})
+
+	require.NoError(t, defaultCapabilityFunc(ctx, c))
+	require.Equal(t, []string{"CAP LS 302\r\n"}, drainCommands(t, c))
+
+	for _, raw := range []string{
+		":irc.example.com CAP * LS * :cap-notify server-time",
+		":irc.example.com CAP * LS :away-notify batch",
+	} {
+		require.NoError(t, defaultCapabilityHandler(ctx, c, mustDecode(t, c, raw).(Command)))
+	}
+
+	require.Equal(t, []string{"CAP REQ :cap-notify away-notify server-time\r\n"}, drainCommands(t, c))
+
+	ack := mustDecode(t, c, ":irc.example.com CAP tenyks ACK :cap-notify away-notify server-time")
+	require.NoError(t, defaultCapabilityHandler(ctx, c, ack.(Command)))
+
+	require.Equal(t, []string{"CAP END\r\n"}, drainCommands(t, c))
+	require.True(t, c.HasCapability("server-time"))
+	require.False(t, c.HasCapability("batch"))
+	require.Equal(t, []string{"away-notify", "cap-notify", "server-time"}, enabled)
+
+	del := mustDecode(t, c, ":irc.example.com CAP tenyks DEL :away-notify")
+	require.NoError(t, defaultCapabilityHandler(ctx, c, del.(Command)))
+	require.False(t, c.HasCapability("away-notify"))
+
+	neu := mustDecode(t, c, ":irc.example.com CAP tenyks NEW :away-notify")
+	require.NoError(t, defaultCapabilityHandler(ctx, c, neu.(Command)))
+	require.Equal(t, []string{"CAP REQ :away-notify\r\n"}, drainCommands(t, c))
+}
+
+func TestCapabilityNegotiationHold(t *testing.T) {
+	ctx := context.Background()
+	c := newTestConnection(t, Config{Capabilities: []string{"sasl"}})
+
+	c.OnCapability = append(c.OnCapability, func(_ context.Context, conn *Connection, cap Capability, on bool) error {
+		if cap.Name == "sasl" && on {
+			conn.HoldRegistration()
+		}
+
+		return nil
+	})
+
+	require.NoError(t, defaultCapabilityFunc(ctx, c))
+
+	ls := mustDecode(t, c, ":irc.example.com CAP * LS :sasl")
+	require.NoError(t, defaultCapabilityHandler(ctx, c, ls.(Command)))
+
+	ack := mustDecode(t, c, ":irc.example.com CAP * ACK :sasl")
+	require.NoError(t, defaultCapabilityHandler(ctx, c, ack.(Command)))
+
+	require.Equal(t, []string{"CAP LS 302\r\n", "CAP REQ :sasl\r\n"}, drainCommands(t, c))
+
+	require.NoError(t, c.ReleaseRegistration(ctx))
+	require.Equal(t, []string{"CAP END\r\n"}, drainCommands(t, c))
+}
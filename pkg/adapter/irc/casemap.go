@@ -0,0 +1,97 @@
+package irc
+
+import (
+	"strings"
+)
+
+// CaseMapping is how the server decides two nicks or channel names are the
+// same. It's advertised with the CASEMAPPING token of RPL_ISUPPORT.
+type CaseMapping string
+
+const (
+	// CaseMappingASCII only folds A-Z.
+	CaseMappingASCII CaseMapping = "ascii"
+	// CaseMappingRFC1459 folds A-Z and also treats {}|~ as the lower case of
+	// []\^, because of Scandinavian character sets. It's the default when the
+	// server doesn't say.
+	CaseMappingRFC1459 CaseMapping = "rfc1459"
+	// CaseMappingRFC1459Strict is rfc1459 without ^ and ~.
+	CaseMappingRFC1459Strict CaseMapping = "rfc1459-strict"
+)
+
+// Fold returns the lower case form of name. Names that fold to the same
+// string refer to the same nick or channel. Mappings we don't know, like
+// rfc7613, fall back to unicode lower casing.
+func (cm CaseMapping) Fold(name string) string {
+	var upper byte
+
+	switch cm {
+	case CaseMappingASCII:
+		upper = 'Z'
+	case CaseMappingRFC1459, "":
+		upper = '^'
+	case CaseMappingRFC1459Strict:
+		upper = ']'
+	default:
+		return strings.ToLower(name)
+	}
+
+	// A-Z are 65-90 and []\^ directly follow at 91-94. Their lower case
+	// forms are all 32 higher.
+	return strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= rune(upper) {
+			return r + 32
+		}
+
+		return r
+	}, name)
+}
+
+// Equal returns true if a and b are the same name under the case mapping.
+func (cm CaseMapping) Equal(a, b string) bool {
+	return cm.Fold(a) == cm.Fold(b)
+}
+
+// fold returns the key used for name in the channel and nick maps. The
+// caller must hold the connection's lock.
+func (c *Connection) fold(name string) string {
+	return c.features.CaseMapping.Fold(name)
+}
+
+// isSelf returns true if nick is our current nick.
+func (c *Connection) isSelf(nick string) bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.isCurrentNick(nick)
+}
+
+// isCurrentNick is isSelf for callers that hold the connection's lock.
+func (c *Connection) isCurrentNick(nick string) bool {
+	return c.Status.CurrentNick != "" && c.features.CaseMapping.Equal(nick, c.Status.CurrentNick)
+}
+
+// refold rebuilds the channel and nick maps when the server's case mapping
+// differs from the one we assumed. The caller must hold the connection's
+// lock.
+func (c *Connection) refold() {
+	channels := make(map[string]*Channel, len(c.channels))
+
+	for _, channel := range c.channels {
+		nicks := make(map[string]*Nick, len(channel.Status.Nicks))
+
+		for _, nick := range channel.Status.Nicks {
+			nicks[c.fold(nick.Name)] = nick
+		}
+
+		channel.Status.Nicks = nicks
+		channels[c.fold(channel.Name)] = channel
+	}
+
+	c.channels = channels
+
+	// collected NAMES and lists are keyed by the old mapping, so start over. The next
+	// NAMES will fill them in again.
+	c.pendingNames = map[string]map[string]*Nick{}
+	c.pendingLists = map[string]map[byte][]string{}
+}
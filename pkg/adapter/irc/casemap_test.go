@@ -0,0 +1,62 @@
+package irc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaseMappingFold(t *testing.T) {
+	tests := []struct {
+		mapping  CaseMapping
+		name     string
+		expected string
+	}{
+		{CaseMappingASCII, "Tenyks[]\\^", "tenyks[]\\^"},
+		{CaseMappingRFC1459, "Tenyks[]\\^", "tenyks{}|~"},
+		{CaseMappingRFC1459Strict, "Tenyks[]\\^", "tenyks{}|^"},
+		{CaseMapping("rfc7613"), "TÉNYKS", "tényks"},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.mapping), func(t *testing.T) {
+			require.Equal(t, test.expected, test.mapping.Fold(test.name))
+		})
+	}
+
+	require.True(t, CaseMappingRFC1459.Equal("#Tenyks[a]", "#tenyks{A}"))
+	require.False(t, CaseMappingASCII.Equal("#tenyks[a]", "#tenyks{a}"))
+}
+
+func TestCaseInsensitiveChannelTracking(t *testing.T) {
+	c := newTestConnection(t, Config{Channels: []string{"#Tenyks[dev]"}})
+	ctx := context.Background()
+
+	c.Status.CurrentNick = "Tenyks"
+
+	join := mustDecode(t, c, ":tenyks!~tenyks@example.com JOIN #tenyks{dev}").(Command)
+	require.NoError(t, defaultJoinChannelStatusUpdater(ctx, c, join))
+
+	channel, ok := c.channels["#tenyks{dev}"]
+	require.True(t, ok)
+	require.Equal(t, "#Tenyks[dev]", channel.Name)
+	require.Equal(t, ChannelStatusJoined, channel.Status.Status)
+
+	names := mustDecode(t, c, ":irc.test 353 Tenyks = #TENYKS[DEV] :@Kyle other").(Reply)
+	require.NoError(t, defaultChannelMemberUpdater(ctx, c, names))
+
+	end := mustDecode(t, c, ":irc.test 366 Tenyks #tenyks[dev] :End of /NAMES list.").(Reply)
+	require.NoError(t, defaultChannelMemberUpdater(ctx, c, end))
+	require.Contains(t, channel.Status.Nicks, "kyle")
+	require.Equal(t, "Kyle", channel.Status.Nicks["kyle"].Name)
+
+	// switching to ascii means [] no longer fold to {}
+	isupport := mustDecode(t, c, ":irc.test 005 Tenyks CASEMAPPING=ascii :are supported by this server").(Reply)
+	require.NoError(t, defaultServerFeaturesHandler(ctx, c, isupport))
+	require.Contains(t, c.channels, "#tenyks[dev]")
+	require.NotContains(t, c.channels, "#tenyks{dev}")
+
+	require.NoError(t, defaultJoinFunc(ctx, c))
+	require.Equal(t, []string{"JOIN #Tenyks[dev]\r\n"}, drainCommands(t, c))
+}
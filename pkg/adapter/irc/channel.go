@@ -1,5 +1,10 @@
 package irc
 
+import (
+	"strconv"
+	"time"
+)
+
 type ChannelStatusType int
 
 const (
@@ -20,23 +25,115 @@ const (
 // Err means the connection received an error reply when attempting to join, or
 // the connection was banned or kicked at some point. The Nicks map will then
 // be empty and a message about the error will be set on Message.
+//
+// Modes holds the channel's modes mapped to their parameter, or an empty
+// string for modes without one. List modes like bans are kept in Lists
+// instead.
 type ChannelStatus struct {
 	Status  ChannelStatusType
 	Message string
 	Nicks   map[string]*Nick
+	Modes   map[byte]string
+	Lists   map[byte][]string
+	// Topic is the channel topic, who set it and when.
+	Topic      string
+	TopicSetBy string
+	TopicSetAt time.Time
+}
+
+// Key returns the channel key, or an empty string if there isn't one.
+func (s *ChannelStatus) Key() string {
+	return s.Modes['k']
+}
+
+// Limit returns the user limit, or 0 if there isn't one.
+func (s *ChannelStatus) Limit() int {
+	limit, _ := strconv.Atoi(s.Modes['l'])
+
+	return limit
+}
+
+// IsModerated returns true if only voiced members can talk.
+func (s *ChannelStatus) IsModerated() bool {
+	_, ok := s.Modes['m']
+
+	return ok
+}
+
+// Bans returns the ban masks we know about.
+func (s *ChannelStatus) Bans() []string {
+	return s.Lists['b']
+}
+
+// Excepts returns the ban exception masks we know about.
+func (s *ChannelStatus) Excepts() []string {
+	return s.Lists['e']
+}
+
+// Invites returns the invite exception masks we know about.
+func (s *ChannelStatus) Invites() []string {
+	return s.Lists['I']
+}
+
+// reset forgets everything we knew about the channel while we were in it.
+func (s *ChannelStatus) reset() {
+	s.Nicks = make(map[string]*Nick)
+	s.Modes = make(map[byte]string)
+	s.Lists = make(map[byte][]string)
+	s.Topic = ""
+	s.TopicSetBy = ""
+	s.TopicSetAt = time.Time{}
+}
+
+// RejoinPolicy decides what happens after we're kicked from a channel or
+// the server refuses to let us in.
+type RejoinPolicy string
+
+const (
+	// RejoinPolicyNever leaves the channel in the error state.
+	RejoinPolicyNever RejoinPolicy = "never"
+	// RejoinPolicyDelay tries to join again after a delay that grows with
+	// every failure.
+	RejoinPolicyDelay RejoinPolicy = "delay"
+	// RejoinPolicyAfterSASL is like RejoinPolicyDelay, but waits until we're
+	// logged into a services account first. Use it for channels that only
+	// let registered users in.
+	RejoinPolicyAfterSASL RejoinPolicy = "after-sasl"
+)
+
+func validRejoinPolicy(policy RejoinPolicy) bool {
+	switch policy {
+	case RejoinPolicyNever, RejoinPolicyDelay, RejoinPolicyAfterSASL:
+		return true
+	}
+
+	return false
 }
 
 type Channel struct {
-	Name   string
-	Status *ChannelStatus
+	Name string
+	// Key is sent with our JOIN for channels with mode +k set.
+	Key          string
+	Status       *ChannelStatus
+	RejoinPolicy RejoinPolicy
+
+	// rejoin spaces out attempts to get back into the channel and
+	// rejoinPending is true while one is scheduled.
+	rejoin        backoff
+	rejoinPending bool
 }
 
 func NewChannel(name string) *Channel {
-	return &Channel{
+	channel := &Channel{
 		Name: name,
 		Status: &ChannelStatus{
 			Status: ChannelStatusParted,
-			Nicks:  make(map[string]*Nick),
 		},
+		RejoinPolicy: DefaultRejoinPolicy,
+		rejoin:       backoff{min: DefaultRejoinDelay, max: DefaultRejoinMaxDelay},
 	}
+
+	channel.Status.reset()
+
+	return channel
 }
@@ -30,15 +30,63 @@ var DefaultCommandFactory = map[CommandType]func(*Message) Command{
 	CommandTypeJoin: func(msg *Message) Command {
 		return &JoinCommand{m: msg}
 	},
+	CommandTypePart: func(msg *Message) Command {
+		return &PartCommand{m: msg}
+	},
+	CommandTypeQuit: func(msg *Message) Command {
+		return &QuitCommand{m: msg}
+	},
+	CommandTypeKick: func(msg *Message) Command {
+		return &KickCommand{m: msg}
+	},
+	CommandTypeMode: func(msg *Message) Command {
+		return &ModeCommand{m: msg}
+	},
+	CommandTypeTopic: func(msg *Message) Command {
+		return &TopicCommand{m: msg}
+	},
 	CommandTypePrivmsg: func(msg *Message) Command {
 		return &PrivmsgCommand{m: msg}
 	},
+	CommandTypeNotice: func(msg *Message) Command {
+		return &NoticeCommand{m: msg}
+	},
+	CommandTypeTagmsg: func(msg *Message) Command {
+		return &TagmsgCommand{m: msg}
+	},
+	CommandTypeInvite: func(msg *Message) Command {
+		return &InviteCommand{m: msg}
+	},
+	CommandTypeWho: func(msg *Message) Command {
+		return &WhoCommand{m: msg}
+	},
+	CommandTypeAccount: func(msg *Message) Command {
+		return &AccountCommand{m: msg}
+	},
+	CommandTypeAway: func(msg *Message) Command {
+		return &AwayCommand{m: msg}
+	},
+	CommandTypeChghost: func(msg *Message) Command {
+		return &ChghostCommand{m: msg}
+	},
+	CommandTypeWhois: func(msg *Message) Command {
+		return &WhoisCommand{m: msg}
+	},
 	CommandTypePing: func(msg *Message) Command {
 		return &PingCommand{m: msg}
 	},
 	CommandTypePong: func(msg *Message) Command {
 		return &PongCommand{m: msg}
 	},
+	CommandTypeCap: func(msg *Message) Command {
+		return &CapCommand{m: msg}
+	},
+	CommandTypeAuthenticate: func(msg *Message) Command {
+		return &AuthenticateCommand{m: msg}
+	},
+	CommandTypeError: func(msg *Message) Command {
+		return &ErrorCommand{m: msg}
+	},
 }
 
 type PassCommand struct {
@@ -119,13 +167,23 @@ func (n NickCommand) Message() *Message {
 }
 
 func (n NickCommand) Validate() error {
-	if len(n.m.Params) != 1 {
+	if len(n.m.Params) != 1 && n.m.Trail == "" {
 		return errors.New("NICK command: nick string parameter is required")
 	}
 
 	return nil
 }
 
+// Nick returns the new nick. Servers sometimes send it as a trailing
+// parameter.
+func (n NickCommand) Nick() string {
+	if len(n.m.Params) > 0 {
+		return n.m.Params[0]
+	}
+
+	return n.m.Trail
+}
+
 func NewNickCommand(nick string) *NickCommand {
 	return &NickCommand{
 		m: &Message{
@@ -149,13 +207,49 @@ func (j JoinCommand) Message() *Message {
 }
 
 func (j JoinCommand) Validate() error {
-	if len(j.m.Params) < 1 {
+	if len(j.m.Params) < 1 && j.m.Trail == "" {
 		return errors.New("JOIN command: channels parameter is required")
 	}
 
 	return nil
 }
 
+// Channel returns the channel that was joined. Some servers send it as a
+// trailing parameter.
+func (j JoinCommand) Channel() string {
+	if len(j.m.Params) > 0 {
+		return j.m.Params[0]
+	}
+
+	return j.m.Trail
+}
+
+// Account returns the account of the user that joined when the server uses
+// extended-join, or an empty string if they aren't logged in.
+func (j JoinCommand) Account() string {
+	if len(j.m.Params) < 2 || j.m.Params[1] == "*" {
+		return ""
+	}
+
+	return j.m.Params[1]
+}
+
+// RealName returns the real name of the user that joined when the server
+// uses extended-join.
+func (j JoinCommand) RealName() string {
+	if len(j.m.Params) < 2 {
+		return ""
+	}
+
+	return j.m.Trail
+}
+
+// IsExtended returns true for JOINs in the extended-join format, which
+// include the account and real name of the user.
+func (j JoinCommand) IsExtended() bool {
+	return len(j.m.Params) >= 2
+}
+
 func NewJoinCommand(channels ...string) *JoinCommand {
 	// TODO validate channel name
 	return &JoinCommand{
@@ -167,6 +261,483 @@ func NewJoinCommand(channels ...string) *JoinCommand {
 	}
 }
 
+// NewKeyedJoinCommand joins channels using keys. JOIN pairs keys with
+// channels by position, so channels that need a key must come first.
+func NewKeyedJoinCommand(channels, keys []string) *JoinCommand {
+	cmd := NewJoinCommand(channels...)
+
+	if len(keys) > 0 {
+		cmd.m.Params = append(cmd.m.Params, strings.Join(keys, ","))
+	}
+
+	return cmd
+}
+
+type PartCommand struct {
+	m *Message
+}
+
+func (p PartCommand) Encode() (string, error) {
+	return NewRawMessageEncoder().Encode(p.m)
+}
+
+func (p PartCommand) Message() *Message {
+	return p.m
+}
+
+func (p PartCommand) Validate() error {
+	if len(p.m.Params) < 1 && p.m.Trail == "" {
+		return errors.New("PART command: channels parameter is required")
+	}
+
+	return nil
+}
+
+// Channel returns the channel that was left.
+func (p PartCommand) Channel() string {
+	if len(p.m.Params) > 0 {
+		return p.m.Params[0]
+	}
+
+	return p.m.Trail
+}
+
+// Reason returns the part message, if there is one.
+func (p PartCommand) Reason() string {
+	if len(p.m.Params) == 0 {
+		return ""
+	}
+
+	return p.m.Trail
+}
+
+func NewPartCommand(reason string, channels ...string) *PartCommand {
+	return &PartCommand{
+		m: &Message{
+			Command:     "PART",
+			MessageType: MessageTypeCommand,
+			Params:      []string{strings.Join(channels, ",")},
+			Trail:       reason,
+		},
+	}
+}
+
+type QuitCommand struct {
+	m *Message
+}
+
+func (q QuitCommand) Encode() (string, error) {
+	return NewRawMessageEncoder().Encode(q.m)
+}
+
+func (q QuitCommand) Message() *Message {
+	return q.m
+}
+
+func (q QuitCommand) Validate() error {
+	return nil
+}
+
+// Reason returns the quit message.
+func (q QuitCommand) Reason() string {
+	return q.m.Trail
+}
+
+func NewQuitCommand(reason string) *QuitCommand {
+	return &QuitCommand{
+		m: &Message{
+			Command:     "QUIT",
+			MessageType: MessageTypeCommand,
+			Trail:       reason,
+		},
+	}
+}
+
+type KickCommand struct {
+	m *Message
+}
+
+func (k KickCommand) Encode() (string, error) {
+	return NewRawMessageEncoder().Encode(k.m)
+}
+
+func (k KickCommand) Message() *Message {
+	return k.m
+}
+
+func (k KickCommand) Validate() error {
+	if len(k.m.Params) != 2 {
+		return errors.New("KICK command: channel and nick parameters are required")
+	}
+
+	return nil
+}
+
+// Channel returns the channel the nick was kicked from.
+func (k KickCommand) Channel() string {
+	return k.m.Params[0]
+}
+
+// Nick returns the nick that was kicked.
+func (k KickCommand) Nick() string {
+	return k.m.Params[1]
+}
+
+// Reason returns the kick message.
+func (k KickCommand) Reason() string {
+	return k.m.Trail
+}
+
+func NewKickCommand(channel, nick, reason string) *KickCommand {
+	return &KickCommand{
+		m: &Message{
+			Command:     "KICK",
+			MessageType: MessageTypeCommand,
+			Params:      []string{channel, nick},
+			Trail:       reason,
+		},
+	}
+}
+
+// ModeCommand changes or queries the modes of a channel or user.
+type ModeCommand struct {
+	m *Message
+}
+
+func (mc ModeCommand) Encode() (string, error) {
+	return NewRawMessageEncoder().Encode(mc.m)
+}
+
+func (mc ModeCommand) Message() *Message {
+	return mc.m
+}
+
+func (mc ModeCommand) Validate() error {
+	if len(mc.m.Params) < 1 {
+		return errors.New("MODE command: target parameter is required")
+	}
+
+	return nil
+}
+
+// Target returns the channel or nick whose modes changed.
+func (mc ModeCommand) Target() string {
+	return mc.m.Params[0]
+}
+
+// Changes parses the mode string and its parameters. The server's features
+// are needed to know which modes take a parameter.
+func (mc ModeCommand) Changes(features *ServerFeatures) []ModeChange {
+	args := append([]string{}, mc.m.Params[1:]...)
+
+	if mc.m.Trail != "" {
+		args = append(args, mc.m.Trail)
+	}
+
+	if len(args) == 0 {
+		return nil
+	}
+
+	return features.ParseModes(args[0], args[1:])
+}
+
+// NewModeCommand returns a MODE command for target. Without modes it asks
+// the server for target's current modes.
+func NewModeCommand(target string, modes ...string) *ModeCommand {
+	return &ModeCommand{
+		m: &Message{
+			Command:     "MODE",
+			MessageType: MessageTypeCommand,
+			Params:      append([]string{target}, modes...),
+		},
+	}
+}
+
+// TopicCommand sets a channel's topic. The server sends it to everyone in
+// the channel when someone changes the topic.
+type TopicCommand struct {
+	m *Message
+}
+
+func (t TopicCommand) Encode() (string, error) {
+	return NewRawMessageEncoder().Encode(t.m)
+}
+
+func (t TopicCommand) Message() *Message {
+	return t.m
+}
+
+func (t TopicCommand) Validate() error {
+	if len(t.m.Params) < 1 {
+		return errors.New("TOPIC command: channel parameter is required")
+	}
+
+	return nil
+}
+
+func (t TopicCommand) Channel() string {
+	return t.m.Params[0]
+}
+
+// Topic returns the new topic. It's empty if the topic was cleared.
+func (t TopicCommand) Topic() string {
+	return t.m.Trail
+}
+
+// NewTopicCommand returns a command that sets the topic of channel. If topic
+// is empty, the server replies with the current topic instead. Use
+// NewClearTopicCommand to clear it.
+func NewTopicCommand(channel, topic string) *TopicCommand {
+	return &TopicCommand{
+		m: &Message{
+			Command:     "TOPIC",
+			MessageType: MessageTypeCommand,
+			Params:      []string{channel},
+			Trail:       topic,
+		},
+	}
+}
+
+// NewClearTopicCommand returns a command that removes the topic of channel.
+func NewClearTopicCommand(channel string) *TopicCommand {
+	cmd := NewTopicCommand(channel, "")
+	cmd.m.EmptyTrail = true
+
+	return cmd
+}
+
+// InviteCommand is an INVITE. We get one when someone invites us into a
+// channel.
+type InviteCommand struct {
+	m *Message
+}
+
+func (i InviteCommand) Encode() (string, error) {
+	return NewRawMessageEncoder().Encode(i.m)
+}
+
+func (i InviteCommand) Message() *Message {
+	return i.m
+}
+
+func (i InviteCommand) Validate() error {
+	if len(i.m.Params) < 2 && !(len(i.m.Params) == 1 && i.m.Trail != "") {
+		return errors.New("INVITE command: nick and channel parameters are required")
+	}
+
+	return nil
+}
+
+// Nick returns the nick being invited.
+func (i InviteCommand) Nick() string {
+	return i.m.Params[0]
+}
+
+func (i InviteCommand) Channel() string {
+	if len(i.m.Params) > 1 {
+		return i.m.Params[1]
+	}
+
+	return i.m.Trail
+}
+
+func NewInviteCommand(nick, channel string) *InviteCommand {
+	return &InviteCommand{
+		m: &Message{
+			Command:     "INVITE",
+			MessageType: MessageTypeCommand,
+			Params:      []string{nick, channel},
+		},
+	}
+}
+
+// WhoCommand asks the server about the users matching a mask, or the members
+// of a channel.
+type WhoCommand struct {
+	m *Message
+}
+
+func (w WhoCommand) Encode() (string, error) {
+	return NewRawMessageEncoder().Encode(w.m)
+}
+
+func (w WhoCommand) Message() *Message {
+	return w.m
+}
+
+func (w WhoCommand) Validate() error {
+	if len(w.m.Params) < 1 {
+		return errors.New("WHO command: mask parameter is required")
+	}
+
+	return nil
+}
+
+func (w WhoCommand) Mask() string {
+	return w.m.Params[0]
+}
+
+// NewWhoCommand asks about mask. With WHOX, fields picks the details the
+// server sends back, like %tcuhnfar,42.
+func NewWhoCommand(mask string, fields ...string) *WhoCommand {
+	return &WhoCommand{
+		m: &Message{
+			Command:     "WHO",
+			MessageType: MessageTypeCommand,
+			Params:      append([]string{mask}, fields...),
+		},
+	}
+}
+
+// WhoisCommand asks the server for the details of a single user.
+type WhoisCommand struct {
+	m *Message
+}
+
+func (w WhoisCommand) Encode() (string, error) {
+	return NewRawMessageEncoder().Encode(w.m)
+}
+
+func (w WhoisCommand) Message() *Message {
+	return w.m
+}
+
+func (w WhoisCommand) Validate() error {
+	if len(w.m.Params) < 1 {
+		return errors.New("WHOIS command: nick parameter is required")
+	}
+
+	return nil
+}
+
+// Nick returns the user being asked about. A leading server parameter is
+// skipped.
+func (w WhoisCommand) Nick() string {
+	return w.m.Params[len(w.m.Params)-1]
+}
+
+func NewWhoisCommand(nick string) *WhoisCommand {
+	return &WhoisCommand{
+		m: &Message{
+			Command:     "WHOIS",
+			MessageType: MessageTypeCommand,
+			Params:      []string{nick},
+		},
+	}
+}
+
+// AccountCommand is sent with account-notify when a user logs into or out of
+// their services account.
+type AccountCommand struct {
+	m *Message
+}
+
+func (a AccountCommand) Encode() (string, error) {
+	return NewRawMessageEncoder().Encode(a.m)
+}
+
+func (a AccountCommand) Message() *Message {
+	return a.m
+}
+
+func (a AccountCommand) Validate() error {
+	if len(a.m.Params) < 1 && a.m.Trail == "" {
+		return errors.New("ACCOUNT command: account parameter is required")
+	}
+
+	return nil
+}
+
+// Account returns the account the user is now logged into, or an empty
+// string if they logged out.
+func (a AccountCommand) Account() string {
+	account := a.m.Trail
+	if len(a.m.Params) > 0 {
+		account = a.m.Params[0]
+	}
+
+	if account == "*" {
+		return ""
+	}
+
+	return account
+}
+
+// AwayCommand is sent with away-notify when a user marks themselves as away
+// or back. We send it to set our own away message.
+type AwayCommand struct {
+	m *Message
+}
+
+func (a AwayCommand) Encode() (string, error) {
+	return NewRawMessageEncoder().Encode(a.m)
+}
+
+func (a AwayCommand) Message() *Message {
+	return a.m
+}
+
+func (a AwayCommand) Validate() error {
+	return nil
+}
+
+// IsAway returns true if the user is now away.
+func (a AwayCommand) IsAway() bool {
+	return a.Reason() != ""
+}
+
+func (a AwayCommand) Reason() string {
+	if a.m.Trail == "" && len(a.m.Params) > 0 {
+		return a.m.Params[0]
+	}
+
+	return a.m.Trail
+}
+
+// NewAwayCommand marks us as away with reason, or back if it's empty.
+func NewAwayCommand(reason string) *AwayCommand {
+	return &AwayCommand{
+		m: &Message{
+			Command:     "AWAY",
+			MessageType: MessageTypeCommand,
+			Trail:       reason,
+		},
+	}
+}
+
+// ChghostCommand is sent with chghost when a user's ident or host changes.
+type ChghostCommand struct {
+	m *Message
+}
+
+func (c ChghostCommand) Encode() (string, error) {
+	return NewRawMessageEncoder().Encode(c.m)
+}
+
+func (c ChghostCommand) Message() *Message {
+	return c.m
+}
+
+func (c ChghostCommand) Validate() error {
+	if len(c.m.Params) < 2 && !(len(c.m.Params) == 1 && c.m.Trail != "") {
+		return errors.New("CHGHOST command: ident and host parameters are required")
+	}
+
+	return nil
+}
+
+func (c ChghostCommand) Ident() string {
+	return c.m.Params[0]
+}
+
+func (c ChghostCommand) Host() string {
+	if len(c.m.Params) > 1 {
+		return c.m.Params[1]
+	}
+
+	return c.m.Trail
+}
+
 type PrivmsgCommand struct {
 	m             *Message
 	isDirectFunc  func(*Message) bool
@@ -209,6 +780,19 @@ func (p PrivmsgCommand) IsMention() bool {
 	return p.isMentionFunc(p.m)
 }
 
+// CTCP returns the CTCP query carried by the message, if there is one.
+func (p PrivmsgCommand) CTCP() (CTCPMessage, bool) {
+	return ParseCTCP(p.m.Trail)
+}
+
+// IsAction returns true if the message is a CTCP ACTION, which clients show
+// as "* nick does something".
+func (p PrivmsgCommand) IsAction() bool {
+	ctcp, ok := p.CTCP()
+
+	return ok && ctcp.Command == CTCPAction
+}
+
 func NewPrivmsgCommand(target string, msg string) *PrivmsgCommand {
 	return &PrivmsgCommand{
 		m: &Message{
@@ -220,6 +804,109 @@ func NewPrivmsgCommand(target string, msg string) *PrivmsgCommand {
 	}
 }
 
+// NewCTCPCommand sends a CTCP query, or an ACTION, to target.
+func NewCTCPCommand(target string, ctcp CTCPMessage) *PrivmsgCommand {
+	return NewPrivmsgCommand(target, ctcp.String())
+}
+
+// NoticeCommand is a NOTICE. Unlike PRIVMSG, clients must never reply to a
+// NOTICE automatically, which is why CTCP replies are sent with it.
+type NoticeCommand struct {
+	m *Message
+}
+
+func (n NoticeCommand) Encode() (string, error) {
+	return NewRawMessageEncoder().Encode(n.m)
+}
+
+func (n NoticeCommand) Message() *Message {
+	return n.m
+}
+
+func (n NoticeCommand) Validate() error {
+	if len(n.m.Params) != 1 {
+		return errors.New("NOTICE command: wrong number of parameters")
+	}
+
+	if n.m.Trail == "" {
+		return errors.New("NOTICE command: a message is required")
+	}
+
+	return nil
+}
+
+func (n NoticeCommand) Target() string {
+	return n.m.Params[0]
+}
+
+// CTCP returns the CTCP reply carried by the notice, if there is one.
+func (n NoticeCommand) CTCP() (CTCPMessage, bool) {
+	return ParseCTCP(n.m.Trail)
+}
+
+func NewNoticeCommand(target, msg string) *NoticeCommand {
+	return &NoticeCommand{
+		m: &Message{
+			Command:     "NOTICE",
+			MessageType: MessageTypeCommand,
+			Params:      []string{target},
+			Trail:       msg,
+		},
+	}
+}
+
+// NewCTCPReplyCommand answers a CTCP query from target.
+func NewCTCPReplyCommand(target string, ctcp CTCPMessage) *NoticeCommand {
+	return NewNoticeCommand(target, ctcp.String())
+}
+
+// TagmsgCommand is a message with tags but no text. It carries client tags
+// like reactions. Servers only accept it with the message-tags capability.
+type TagmsgCommand struct {
+	m *Message
+}
+
+func (t TagmsgCommand) Encode() (string, error) {
+	return NewRawMessageEncoder().Encode(t.m)
+}
+
+func (t TagmsgCommand) Message() *Message {
+	return t.m
+}
+
+func (t TagmsgCommand) Validate() error {
+	if len(t.m.Params) != 1 {
+		return errors.New("TAGMSG command: wrong number of parameters")
+	}
+
+	if t.m.TagsSection == nil || len(t.m.TagsSection.Tags) == 0 {
+		return errors.New("TAGMSG command: at least one tag is required")
+	}
+
+	return nil
+}
+
+func (t TagmsgCommand) Target() string {
+	return t.m.Params[0]
+}
+
+// NewReactionCommand reacts to the message with msgid in target with
+// reaction, usually an emoji.
+func NewReactionCommand(target, msgid, reaction string) *TagmsgCommand {
+	cmd := &TagmsgCommand{
+		m: &Message{
+			Command:     "TAGMSG",
+			MessageType: MessageTypeCommand,
+			Params:      []string{target},
+		},
+	}
+
+	cmd.m.SetTag(reactTag, reaction)
+	cmd.m.SetTag(replyTag, msgid)
+
+	return cmd
+}
+
 func mentionAndDirectPrivmsgCommand(c *Connection, m *Message) Command {
 	return &PrivmsgCommand{
 		m: m,
@@ -228,7 +915,8 @@ func mentionAndDirectPrivmsgCommand(c *Connection, m *Message) Command {
 				return false
 			}
 
-			return m.Params[0] == c.Status.CurrentNick
+			// anything that isn't sent to a channel was sent to us
+			return !c.Features().IsChannel(m.Params[0])
 		},
 		isMentionFunc: func(m *Message) bool {
 			if c.Status.Connected == false {
@@ -240,7 +928,7 @@ func mentionAndDirectPrivmsgCommand(c *Connection, m *Message) Command {
 				return false
 			}
 
-			return parts[0] == c.Status.CurrentNick
+			return c.isSelf(parts[0])
 		},
 	}
 }
@@ -261,6 +949,16 @@ func (p PingCommand) Validate() error {
 	return nil
 }
 
+func NewPingCommand(token string) *PingCommand {
+	return &PingCommand{
+		m: &Message{
+			Command:     "PING",
+			MessageType: MessageTypeCommand,
+			Trail:       token,
+		},
+	}
+}
+
 type PongCommand struct {
 	m *Message
 }
@@ -277,6 +975,20 @@ func (p PongCommand) Validate() error {
 	return nil
 }
 
+// Token returns the token from the PING this PONG answers. Servers send it
+// as the last parameter, which is usually a trailing one.
+func (p PongCommand) Token() string {
+	if p.m.Trail != "" {
+		return p.m.Trail
+	}
+
+	if len(p.m.Params) > 0 {
+		return p.m.Params[len(p.m.Params)-1]
+	}
+
+	return ""
+}
+
 func NewPongCommand(server string) *PongCommand {
 	return &PongCommand{
 		m: &Message{
@@ -287,6 +999,264 @@ func NewPongCommand(server string) *PongCommand {
 	}
 }
 
+// capSubcommands is the set of subcommands a CAP message can carry. It's used
+// to figure out where the subcommand sits in the parameter list, since server
+// messages include a target before it and client messages don't.
+var capSubcommands = map[string]bool{
+	"LS":   true,
+	"LIST": true,
+	"REQ":  true,
+	"ACK":  true,
+	"NAK":  true,
+	"NEW":  true,
+	"DEL":  true,
+	"END":  true,
+}
+
+// CapCommand is used for IRCv3 capability negotiation.
+// https://ircv3.net/specs/extensions/capability-negotiation
+type CapCommand struct {
+	m *Message
+}
+
+func (c CapCommand) Encode() (string, error) {
+	return NewRawMessageEncoder().Encode(c.m)
+}
+
+func (c CapCommand) Message() *Message {
+	return c.m
+}
+
+func (c CapCommand) Validate() error {
+	if c.subcommandIndex() == -1 {
+		return errors.New("CAP command: a subcommand is required")
+	}
+
+	return nil
+}
+
+// Subcommand returns the CAP subcommand, such as LS, ACK or NEW.
+func (c CapCommand) Subcommand() string {
+	if i := c.subcommandIndex(); i != -1 {
+		return strings.ToUpper(c.m.Params[i])
+	}
+
+	return ""
+}
+
+// IsContinuation returns true if this is one line of a multi-line reply and
+// more lines will follow. Servers mark these lines with a "*" parameter after
+// the subcommand.
+func (c CapCommand) IsContinuation() bool {
+	i := c.subcommandIndex()
+	if i == -1 {
+		return false
+	}
+
+	return len(c.m.Params) > i+1 && c.m.Params[i+1] == "*"
+}
+
+// Capabilities returns the capabilities listed in the command as a map of
+// capability names to values. Capabilities without a value map to an empty
+// string. Capabilities being disabled in an ACK keep their "-" prefix.
+func (c CapCommand) Capabilities() map[string]string {
+	caps := map[string]string{}
+
+	list := c.m.Trail
+	if list == "" {
+		// a single capability can be sent as a middle parameter
+		if i := c.subcommandIndex(); i != -1 && len(c.m.Params) > i+1 {
+			if last := c.m.Params[len(c.m.Params)-1]; last != "*" {
+				list = last
+			}
+		}
+	}
+
+	for _, token := range strings.Fields(list) {
+		parts := strings.SplitN(token, "=", 2)
+
+		var value string
+		if len(parts) == 2 {
+			value = parts[1]
+		}
+
+		caps[parts[0]] = value
+	}
+
+	return caps
+}
+
+func (c CapCommand) subcommandIndex() int {
+	// server messages look like `CAP <target> <subcommand> ...` and client
+	// messages look like `CAP <subcommand> ...`.
+	if len(c.m.Params) > 1 && capSubcommands[strings.ToUpper(c.m.Params[1])] {
+		return 1
+	}
+
+	if len(c.m.Params) > 0 && capSubcommands[strings.ToUpper(c.m.Params[0])] {
+		return 0
+	}
+
+	return -1
+}
+
+// NewCapCommand returns a CAP command with a subcommand and optional extra
+// parameters, such as `CAP LS 302` or `CAP END`.
+func NewCapCommand(subcommand string, params ...string) *CapCommand {
+	return &CapCommand{
+		m: &Message{
+			Command:     "CAP",
+			MessageType: MessageTypeCommand,
+			Params:      append([]string{subcommand}, params...),
+		},
+	}
+}
+
+// NewCapRequestCommand returns a `CAP REQ` command asking the server to
+// enable caps.
+func NewCapRequestCommand(caps ...string) *CapCommand {
+	return &CapCommand{
+		m: &Message{
+			Command:     "CAP",
+			MessageType: MessageTypeCommand,
+			Params:      []string{"REQ"},
+			Trail:       strings.Join(caps, " "),
+		},
+	}
+}
+
+// AuthenticateCommand carries SASL mechanism names and payloads between the
+// client and server.
+// https://ircv3.net/specs/extensions/sasl-3.1
+type AuthenticateCommand struct {
+	m *Message
+}
+
+func (a AuthenticateCommand) Encode() (string, error) {
+	return NewRawMessageEncoder().Encode(a.m)
+}
+
+func (a AuthenticateCommand) Message() *Message {
+	return a.m
+}
+
+func (a AuthenticateCommand) Validate() error {
+	if len(a.m.Params) != 1 {
+		return errors.New("AUTHENTICATE command: wrong number of parameters")
+	}
+
+	if len(a.m.Params[0]) > 400 {
+		return errors.New("AUTHENTICATE command: payload chunks can't be longer than 400 bytes")
+	}
+
+	return nil
+}
+
+// Payload returns the mechanism name or base64 payload chunk. An empty
+// payload is sent as "+".
+func (a AuthenticateCommand) Payload() string {
+	return a.m.Params[0]
+}
+
+func NewAuthenticateCommand(payload string) *AuthenticateCommand {
+	return &AuthenticateCommand{
+		m: &Message{
+			Command:     "AUTHENTICATE",
+			MessageType: MessageTypeCommand,
+			Params:      []string{payload},
+		},
+	}
+}
+
+// ErrorCommand is sent by the server to report a fatal error right before it
+// closes the link.
+type ErrorCommand struct {
+	m *Message
+}
+
+func (e ErrorCommand) Encode() (string, error) {
+	return NewRawMessageEncoder().Encode(e.m)
+}
+
+func (e ErrorCommand) Message() *Message {
+	return e.m
+}
+
+func (e ErrorCommand) Validate() error {
+	return nil
+}
+
+// IsonCommand asks the server which of the given nicks are online.
+type IsonCommand struct {
+	m *Message
+}
+
+func (i IsonCommand) Encode() (string, error) {
+	return NewRawMessageEncoder().Encode(i.m)
+}
+
+func (i IsonCommand) Message() *Message {
+	return i.m
+}
+
+func (i IsonCommand) Validate() error {
+	if len(i.m.Params) < 1 {
+		return errors.New("ISON command: at least one nick is required")
+	}
+
+	return nil
+}
+
+func NewIsonCommand(nicks ...string) *IsonCommand {
+	return &IsonCommand{
+		m: &Message{
+			Command:     "ISON",
+			MessageType: MessageTypeCommand,
+			Params:      nicks,
+		},
+	}
+}
+
+// MonitorCommand adds or removes nicks from the server's MONITOR list.
+// https://ircv3.net/specs/extensions/monitor
+type MonitorCommand struct {
+	m *Message
+}
+
+func (mc MonitorCommand) Encode() (string, error) {
+	return NewRawMessageEncoder().Encode(mc.m)
+}
+
+func (mc MonitorCommand) Message() *Message {
+	return mc.m
+}
+
+func (mc MonitorCommand) Validate() error {
+	if len(mc.m.Params) < 1 {
+		return errors.New("MONITOR command: a modifier is required")
+	}
+
+	return nil
+}
+
+// NewMonitorCommand returns a MONITOR command. modifier is one of +, -, C,
+// L or S.
+func NewMonitorCommand(modifier string, nicks ...string) *MonitorCommand {
+	params := []string{modifier}
+
+	if len(nicks) > 0 {
+		params = append(params, strings.Join(nicks, ","))
+	}
+
+	return &MonitorCommand{
+		m: &Message{
+			Command:     "MONITOR",
+			MessageType: MessageTypeCommand,
+			Params:      params,
+		},
+	}
+}
+
 type UnknownCommand struct {
 	m *Message
 }
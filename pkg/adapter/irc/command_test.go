@@ -39,6 +39,16 @@ func TestCommandEncoding(t *testing.T) {
 			cmd:      NewPrivmsgCommand("#test-channel", "hello, Channel!"),
 			expected: "PRIVMSG #test-channel :hello, Channel!\r\n",
 		},
+		{
+			cmd: func() Command {
+				cmd := NewPrivmsgCommand("#test-channel", "hello, Channel!")
+				cmd.Message().SetTag("+example.com/note", "semi; colon")
+				cmd.Message().SetTag("label", "1")
+
+				return cmd
+			}(),
+			expected: "@+example.com/note=semi\\:\\scolon;label=1 PRIVMSG #test-channel :hello, Channel!\r\n",
+		},
 	}
 
 	for _, c := range cases {
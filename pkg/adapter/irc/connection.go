@@ -1,11 +1,14 @@
 package irc
 
 import (
-	"bufio"
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"math"
 	"net"
+	"path"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,11 +18,14 @@ import (
 )
 
 var (
-	DefaultLoginFunc = defaultLoginFunc
-	DefaultJoinFunc  = defaultJoinFunc
+	DefaultCapabilityFunc = defaultCapabilityFunc
+	DefaultLoginFunc      = defaultLoginFunc
+	DefaultJoinFunc       = defaultJoinFunc
 )
 
 type OnConnectHook func(context.Context, *Connection) error
+type OnRegisteredHook func(context.Context, *Connection) error
+type OnCapabilityHook func(context.Context, *Connection, Capability, bool) error
 type OnDisconnectHook func(context.Context, *Connection) error
 type OnCommandHook func(context.Context, *Connection, Command) error
 type OnReplyHook func(context.Context, *Connection, Reply) error
@@ -37,7 +43,92 @@ type Config struct {
 	Logger   logger.Logger
 	Nicks    []string
 	Channels []string
-	Commands []string
+	// ChannelKeys are the keys for channels in Channels that have one.
+	ChannelKeys map[string]string
+	Commands    []string
+	// Capabilities are IRCv3 capabilities to request in addition to
+	// DefaultCapabilities.
+	Capabilities []string
+	// NickRegainInterval is how often to check if the primary nick is free
+	// when we registered with a fallback and the server doesn't support
+	// MONITOR. It defaults to DefaultNickRegainInterval.
+	NickRegainInterval time.Duration
+	// PingInterval is how long to wait between PINGs we send to the server.
+	// It defaults to DefaultPingInterval.
+	PingInterval time.Duration
+	// PingTimeout is how long to wait for a PONG before reconnecting. It
+	// defaults to DefaultPingTimeout.
+	PingTimeout time.Duration
+	// SASLMechanism is the SASL mechanism used to log into an account during
+	// registration. Supported values are PLAIN and EXTERNAL. SASL is skipped
+	// if this is empty.
+	SASLMechanism string
+	SASLAccount   string
+	SASLPassword  string
+	// SASLTimeout is how long to wait for the server to finish SASL before
+	// giving up and registering without an account. It defaults to
+	// DefaultSASLTimeout.
+	SASLTimeout time.Duration
+	// RootCAPath is a PEM bundle of CAs used to verify the server instead of
+	// the system roots.
+	RootCAPath string
+	// ClientCertPath and ClientKeyPath are a PEM certificate and key presented
+	// to the server. Networks use these for CertFP and SASL EXTERNAL.
+	ClientCertPath string
+	ClientKeyPath  string
+	// TLSServerName overrides the name used for SNI and certificate
+	// verification. It defaults to the host in Server.
+	TLSServerName string
+	// TLSInsecureSkipVerify disables server certificate verification. Only
+	// use this with test servers.
+	TLSInsecureSkipVerify bool
+	// FloodBurst is how many lines can be sent back to back before flood
+	// control kicks in. It defaults to DefaultFloodBurst.
+	FloodBurst int
+	// FloodRate is how long it takes to earn back one line after the burst
+	// is spent. It defaults to DefaultFloodRate and a negative value disables
+	// flood control.
+	FloodRate time.Duration
+	// FloodPenaltyBytes charges long lines an extra line for every
+	// FloodPenaltyBytes bytes. Byte penalties are off when it's zero.
+	FloodPenaltyBytes int
+	// FloodPerTarget gives each PRIVMSG target its own burst and rate on top
+	// of the connection wide one.
+	FloodPerTarget bool
+	// FloodTargetBurst and FloodTargetRate are the burst and rate for each
+	// target when FloodPerTarget is set. They default to half of FloodBurst
+	// and twice FloodRate, so a single target can't use up the connection's
+	// whole allowance.
+	FloodTargetBurst int
+	FloodTargetRate  time.Duration
+	// FloodMaxDelay drops messages that have been waiting to be sent longer
+	// than this. Messages are never dropped when it's zero.
+	FloodMaxDelay time.Duration
+	// RejoinPolicy is what to do after we're kicked from a channel or can't
+	// get into it. It defaults to DefaultRejoinPolicy.
+	RejoinPolicy RejoinPolicy
+	// ChannelRejoinPolicies overrides RejoinPolicy for individual channels.
+	ChannelRejoinPolicies map[string]RejoinPolicy
+	// RejoinDelay and RejoinMaxDelay bound the wait between attempts to get
+	// back into a channel. They default to DefaultRejoinDelay and
+	// DefaultRejoinMaxDelay.
+	RejoinDelay    time.Duration
+	RejoinMaxDelay time.Duration
+	// CTCPVersion is our answer to CTCP VERSION. It defaults to
+	// DefaultCTCPVersion.
+	CTCPVersion string
+	// CTCPIgnore are CTCP queries, like TIME, that we don't answer.
+	CTCPIgnore []string
+	// CTCPReplyRate is how long it takes to earn back a CTCP reply after a
+	// burst of DefaultCTCPReplyBurst. It defaults to DefaultCTCPReplyRate and
+	// a negative value turns CTCP replies off.
+	CTCPReplyRate time.Duration
+	// InviteAllowlist lists who can invite us into channels. Entries are
+	// hostmasks like *!*@trusted.example.com, or $a:account to match a
+	// services account. We join any channel someone on the list invites us
+	// to and ignore everyone else. Invites are ignored when it's empty.
+	// Without account-tag or account-notify, accounts are checked with WHOIS.
+	InviteAllowlist []string
 }
 
 type ConnectionStatus struct {
@@ -47,6 +138,22 @@ type ConnectionStatus struct {
 	StartedAt               time.Time
 	LastServerProbe         time.Time
 	LastServerProbeResponse time.Time
+	// Capabilities are the IRCv3 capabilities the server has acknowledged,
+	// mapped to the value the server advertised for them.
+	Capabilities map[string]string
+	// Account is the services account we are logged into, if any.
+	Account string
+	// TLS describes the TLS session. It's nil for plain text connections.
+	TLS *TLSStatus
+	// Hostmask is our nick!user@host as the server shows it to others. It's
+	// learned from our own JOINs.
+	Hostmask string
+	// Lag is the round trip time of the last PING we sent.
+	Lag time.Duration
+	// FloodDelayed and FloodDropped count the messages flood control held
+	// back and gave up on.
+	FloodDelayed uint64
+	FloodDropped uint64
 }
 
 type Connection struct {
@@ -55,6 +162,8 @@ type Connection struct {
 
 	// event hooks
 	OnConnect    []OnConnectHook
+	OnRegistered []OnRegisteredHook
+	OnCapability []OnCapabilityHook
 	OnDisconnect []OnDisconnectHook
 	OnCommand    []OnCommandHook
 	OnReply      []OnReplyHook
@@ -65,22 +174,52 @@ type Connection struct {
 	ReplyFactory   map[ReplyType]ConnectionReplyFactoryFunc
 
 	channels map[string]*Channel
+	// pendingNames collects NAMES replies for a channel until
+	// RPL_ENDOFNAMES.
+	pendingNames map[string]map[string]*Nick
+	// pendingLists collects ban, exception and invite lists for a channel
+	// until the end of each list.
+	pendingLists map[string]map[byte][]string
 
 	// configuration
-	server   string
-	password string
-	user     string
-	realName string
-	useTLS   bool
-	nicks    []string
-	log      logger.Logger
+	server    string
+	password  string
+	user      string
+	realName  string
+	useTLS    bool
+	tlsConfig *tls.Config
+	caps      *capabilityNegotiator
+	sasl      saslConfig
+	log       logger.Logger
+
+	nickManager        *NickManager
+	nickRegainInterval time.Duration
+	pingInterval       time.Duration
+	pingTimeout        time.Duration
+	flood              *floodControl
+	ctcp               ctcpConfig
+	inviteAllowlist    []string
+	senders            *senderCache
+	rejoinPolicy       RejoinPolicy
+	rejoinBackoff      backoff
+	features           *ServerFeatures
 
 	// managed state
-	conn                net.Conn
+	session             *session
+	backoff             backoff
 	cancel              context.CancelFunc
-	io                  *bufio.ReadWriter
 	in                  chan MessageObject
 	out                 chan Command
+	priority            chan Command
+	restore             []Command
+	restoreReady        chan struct{}
+	retry               Command
+	probe               *probe
+	regainProbe         bool
+	waiters             []*waiter
+	deliveries          []*pendingLine
+	deliveryEvents      chan deliveryEvent
+	labels              uint64
 	chatMessageHandlers []message.HandlerFunc
 
 	sync.RWMutex
@@ -98,105 +237,171 @@ func (c *Connection) RegisterMessageHandler(h message.HandlerFunc) {
 	c.chatMessageHandlers = append(c.chatMessageHandlers, h)
 }
 
+// Dial connects to the server and starts a supervisor that reconnects
+// whenever the connection is lost. It blocks until the first connection is
+// established and the OnConnect hooks have run.
 func (c *Connection) Dial(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	c.cancel = cancel
 
-	b := backoff{max: time.Minute * 5}
+	go c.temporaryDispatcher(ctx)
+	go c.dispatchDeliveryEvents(ctx)
 
-	for {
-		if c.conn != nil {
-			break
-		}
-
-		dialer := net.Dialer{}
-
-		conn, err := dialer.DialContext(ctx, "tcp", c.server)
-		if err != nil {
-			dur := b.next()
-			c.log.Error("connection failed",
-				logger.Param{Key: "connection", Value: c.Name},
-				logger.Param{Key: "retry", Value: dur})
-
-			<-time.After(dur)
+	if err := c.connect(ctx); err != nil {
+		c.teardown(ctx)
+		cancel()
 
-			continue
-		}
-
-		c.conn = conn
+		return err
+	}
 
-		c.io = bufio.NewReadWriter(
-			bufio.NewReader(c.conn),
-			bufio.NewWriter(c.conn),
-		)
+	go c.supervise(ctx)
 
-		in, recvErrs := c.startReceiveLoop(ctx)
-		out, sendErrs := c.startSendLoop(ctx)
+	return nil
+}
 
-		c.in = in
-		c.out = out
+// dialContext opens the socket to the server, performing the TLS handshake
+// if TLS is enabled.
+func (c *Connection) dialContext(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{}
 
-		// TODO replace with OnError hooks
-		go c.monitorErrs(ctx, recvErrs, sendErrs)
-		go c.temporaryDispatcher(ctx)
+	if c.tlsConfig == nil {
+		return dialer.DialContext(ctx, "tcp", c.server)
+	}
 
-		for _, hook := range c.OnConnect {
-			if err := hook(ctx, c); err != nil {
-				return err
-			}
-		}
+	tlsDialer := &tls.Dialer{
+		NetDialer: dialer,
+		Config:    c.tlsConfig,
 	}
 
-	return nil
+	return tlsDialer.DialContext(ctx, "tcp", c.server)
 }
 
+// Close disconnects from the server and stops the supervisor so the
+// connection isn't reestablished.
 func (c *Connection) Close(ctx context.Context) error {
-	if c.Status.Connected {
-		c.conn.Close()
-		c.conn = nil
-
+	if c.cancel != nil {
 		c.cancel()
-
-		close(c.in)
-		close(c.out)
-
-		c.Status.Connected = false
-
-		for _, hook := range c.OnDisconnect {
-			if err := hook(ctx, c); err != nil {
-				return err
-			}
-		}
 	}
 
-	return nil
+	return c.teardown(ctx)
 }
 
 // EnqueueCommand takes a Command, calls its Validate method and puts it on the
 // send queue (out channel). If the out channel's buffer is full, this method
 // will block until some commands are flushed by the io workers and buffer
 // slots are freed up.
+//
+// Commands needed to register and keep the connection alive go on the
+// priority queue instead, which is sent even before registration completes.
+// Everything else waits for registration so nothing queued while
+// disconnected is sent to a server that would reject it.
 func (c *Connection) EnqueueCommand(cmd Command) error {
+	return c.enqueueCommand(context.Background(), cmd)
+}
+
+// enqueueCommand is EnqueueCommand but gives up waiting for room on the
+// queue when ctx is done.
+func (c *Connection) enqueueCommand(ctx context.Context, cmd Command) error {
 	if err := cmd.Validate(); err != nil {
 		return fmt.Errorf("failed to enqueue command; validation failed: %w", err)
 	}
 
-	c.out <- cmd
+	queue := c.out
+	if isPriorityCommand(cmd) {
+		queue = c.priority
+	} else {
+		cmd = &queuedCommand{Command: cmd, queuedAt: time.Now()}
+	}
+
+	select {
+	case queue <- cmd:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// enqueueRestoreCommand queues cmd to be sent before any backlog is flushed.
+// It's used by hooks that restore state after registration, like rejoining
+// channels. Unlike priority commands these go through flood control, so the
+// queue never blocks: a bot in hundreds of channels has a lot of JOINs to
+// pace out.
+//
+// Hooks on the dispatcher use it for everything that isn't a priority
+// command, like asking for a channel's modes after we join it. The
+// dispatcher is what marks a new session as registered, and the send loop
+// doesn't drain the normal queue until then, so a hook waiting on a full
+// normal queue across a reconnect would hold the connection up for good.
+func (c *Connection) enqueueRestoreCommand(cmd Command) error {
+	if err := cmd.Validate(); err != nil {
+		return fmt.Errorf("failed to enqueue command; validation failed: %w", err)
+	}
+
+	c.Lock()
+	c.restore = append(c.restore, cmd)
+	c.Unlock()
+
+	select {
+	case c.restoreReady <- struct{}{}:
+	default:
+	}
 
 	return nil
 }
 
-// SendAsync takes a generic tenyks message and decodes it into a PRIVCMD and
-// attempts to put it on the send queue. See EnqueueCommand for information on
-// potential contention.
-func (c *Connection) SendAsync(_ context.Context, msg message.Message) error {
-	decoder := tenyksChatMessageDecoder{}
-	cmd, err := decoder.Decode(msg)
+// isPriorityCommand returns true for commands that are part of registration
+// or keeping the connection alive.
+func isPriorityCommand(cmd Command) bool {
+	switch cmd.(type) {
+	case *PassCommand, *UserCommand, *NickCommand, *CapCommand, *AuthenticateCommand, *PingCommand, *PongCommand:
+		return true
+	}
+
+	return false
+}
+
+// SendAsync takes a generic tenyks message and decodes it into one or more
+// PRIVMSGs, splitting content that's too long for a single line, and attempts
+// to put them on the send queue. See EnqueueCommand for information on
+// potential contention; SendAsync gives up waiting when ctx is done.
+//
+// Chat messages with a RequestID get a delivery event once the server echoes
+// them back with echo-message, or once they're written to the socket if the
+// server doesn't echo. The event says the message failed if the server
+// refuses it, flood control drops it or it can't be queued. The event goes
+// to the handler set on ctx with message.WithDeliveryHandler, so it gets
+// back to the service that sent the message. That handler is called on its
+// own goroutine and events are dropped if it falls too far behind. Without
+// one the event goes to every registered message handler.
+func (c *Connection) SendAsync(ctx context.Context, msg message.Message) error {
+	decoder := tenyksChatMessageDecoder{
+		budget:      c.privmsgBudget,
+		clientTags:  c.HasCapability("message-tags"),
+		replySender: c.replySender,
+	}
+	cmds, err := decoder.Decode(msg)
 	if err != nil {
 		return fmt.Errorf("failed to send message; decoding failed: %w", err)
 	}
 
-	return c.EnqueueCommand(cmd)
+	var d *delivery
+
+	if chat, ok := msg.(*message.ChatMessage); ok && chat.RequestID != "" {
+		_, target := path.Split(chat.DestinationPath)
+		d = c.trackDelivery(ctx, chat.RequestID, target, cmds)
+	}
+
+	for _, cmd := range cmds {
+		if err := c.enqueueCommand(ctx, cmd); err != nil {
+			if d != nil {
+				c.failDelivery(d, err.Error())
+			}
+
+			return err
+		}
+	}
+
+	return nil
 }
 
 // WithWriteLock returns this connection with it's mutex locked for writing.
@@ -225,98 +430,210 @@ func (c *Connection) monitorErrs(ctx context.Context, in chan error, out chan er
 	for {
 		select {
 		case err = <-in:
-			c.log.Error("receive error", logger.Param{Key: "error", Value: err})
+			err = fmt.Errorf("receive error: %w", err)
 		case err = <-out:
-			c.log.Error("send error", logger.Param{Key: "error", Value: err})
+			err = fmt.Errorf("send error: %w", err)
 		case <-ctx.Done():
 			return
 		}
 
-		for _, hook := range c.OnError {
-			hook(ctx, c, err)
-		}
+		c.dispatchError(ctx, err)
 	}
 }
 
-func (c *Connection) startSendLoop(ctx context.Context) (chan Command, chan error) {
-	outCh := make(chan Command, 10)
+// dispatchError passes err to the OnError hooks. It's used by hooks that
+// need to report a failure that isn't tied to the socket.
+func (c *Connection) dispatchError(ctx context.Context, err error) {
+	for _, hook := range c.OnError {
+		hook(ctx, c, err)
+	}
+}
+
+// startSendLoop writes queued commands to the session's socket. Priority
+// commands are always sent first, followed by the commands restoring the
+// session, and the normal queue is held until the session is registered.
+// Everything but registration and keepalive commands goes through flood
+// control. If a write fails, the command is kept and sent first on the next
+// session.
+func (c *Connection) startSendLoop(ctx context.Context, s *session) chan error {
 	errCh := make(chan error)
 
-	go func() {
-		for {
-			select {
-			case cmd, ok := <-outCh:
-				if !ok {
-					return
-				}
+	report := func(err error) {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+	}
 
-				if err := cmd.Validate(); err != nil {
-					errCh <- err
+	// write sends cmd and returns false if the session ended.
+	write := func(cmd Command) bool {
+		if err := cmd.Validate(); err != nil {
+			report(err)
 
-					continue
-				}
+			return true
+		}
+
+		msg, err := cmd.Encode()
+		if err != nil {
+			report(err)
 
-				msg, err := cmd.Encode()
-				if err != nil {
-					errCh <- err
+			return true
+		}
 
-					continue
+		c.log.Debug(cmd.Message().RawMsg, logger.Param{Key: "direction", Value: "|--->|"})
+
+		if _, err := s.io.WriteString(msg); err == nil {
+			err = s.io.Flush()
+		}
+
+		if err != nil {
+			// priority commands are sent again by the OnConnect hooks
+			// or are stale by the next session.
+			if !isPriorityCommand(cmd) {
+				c.requeue(cmd)
+			}
+
+			s.end(err)
+
+			return false
+		}
+
+		if c.flood != nil {
+			c.flood.take(c.floodTarget(cmd), msg, time.Now())
+		}
+
+		c.messageWritten(cmd)
+
+		return true
+	}
+
+	// the last session's send loop has exited by now, so this doesn't race
+	if c.flood != nil {
+		c.flood.reset()
+	}
+
+	// the OnRegistered hooks restore the new session from scratch
+	c.Lock()
+	c.restore = nil
+	c.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		registered := s.registered
+		var out chan Command
+
+		for {
+			var cmd Command
+
+			// a command that failed to write on the last session goes first,
+			// but not before we're registered again.
+			if out != nil {
+				c.Lock()
+				cmd, c.retry = c.retry, nil
+				c.Unlock()
+			}
+
+			if cmd == nil {
+				// drain the priority queue before looking at anything else
+				select {
+				case cmd = <-c.priority:
+				default:
 				}
+			}
 
-				c.log.Debug(cmd.Message().RawMsg, logger.Param{Key: "direction", Value: "|--->|"})
+			if cmd == nil {
+				c.Lock()
+				if len(c.restore) > 0 {
+					cmd, c.restore = c.restore[0], c.restore[1:]
+				}
+				c.Unlock()
+			}
 
-				if _, err := c.io.WriteString(msg); err != nil {
-					errCh <- err
+			if cmd == nil {
+				select {
+				case <-registered:
+					registered = nil
+					out = c.out
 
 					continue
+				case <-c.restoreReady:
+					continue
+				case cmd = <-c.priority:
+				case cmd = <-out:
+				case <-ctx.Done():
+					return
 				}
+			}
 
-				if err := c.io.Flush(); err != nil {
-					errCh <- err
+			if c.flood != nil && !isPriorityCommand(cmd) {
+				switch c.throttle(ctx, cmd, write) {
+				case throttleDrop:
+					c.messageDropped(cmd)
 
 					continue
+				case throttleStop:
+					return
 				}
+			}
 
-			case <-ctx.Done():
+			if !write(cmd) {
 				return
 			}
 		}
 	}()
 
-	return outCh, errCh
+	return errCh
 }
 
-func (c *Connection) startReceiveLoop(ctx context.Context) (chan MessageObject, chan error) {
-	inCh := make(chan MessageObject, 10)
+// requeue keeps cmd to be sent first once the next session is registered.
+func (c *Connection) requeue(cmd Command) {
+	c.Lock()
+	c.retry = cmd
+	c.Unlock()
+}
+
+// startReceiveLoop reads lines from the session's socket, maps them to
+// commands and replies and puts them on the in channel for the dispatcher.
+// A read error ends the session.
+func (c *Connection) startReceiveLoop(ctx context.Context, s *session) chan error {
 	errCh := make(chan error)
 
+	s.wg.Add(1)
 	go func() {
+		defer s.wg.Done()
+
 		for {
-			select {
-			case <-ctx.Done():
+			line, err := s.io.ReadString('\n')
+			if err != nil {
+				s.end(err)
+
 				return
-			default:
-				line, err := c.io.ReadString('\n')
-				if err != nil {
-					errCh <- err
+			}
 
-					continue
+			mo, err := c.decodeAndMapMessage(line)
+			if err != nil {
+				select {
+				case errCh <- err:
+				case <-ctx.Done():
+					return
 				}
 
-				mo, err := c.decodeAndMapMessage(line)
-				if err != nil {
-					errCh <- err
-					continue
-				}
+				continue
+			}
 
-				c.log.Debug(mo.Message().RawMsg, logger.Param{Key: "direction", Value: "|<---|"})
+			c.log.Debug(mo.Message().RawMsg, logger.Param{Key: "direction", Value: "|<---|"})
 
-				inCh <- mo
+			select {
+			case c.in <- mo:
+			case <-ctx.Done():
+				return
 			}
 		}
 	}()
 
-	return inCh, errCh
+	return errCh
 }
 
 func (c *Connection) decodeAndMapMessage(raw string) (MessageObject, error) {
@@ -380,44 +697,207 @@ func New(conf Config) (*Connection, error) {
 		return nil, err
 	}
 
+	if conf.SASLMechanism != "" && !validSASLMechanism(strings.ToUpper(conf.SASLMechanism)) {
+		return nil, fmt.Errorf("%w: %s", ErrSASLMechanismUnsupported, conf.SASLMechanism)
+	}
+
+	tlsConfig, err := newTLSConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(conf.SASLMechanism, SASLMechanismExternal) && (tlsConfig == nil || len(tlsConfig.Certificates) == 0) {
+		return nil, fmt.Errorf("%w: EXTERNAL requires TLS with a client certificate", ErrSASLMechanismUnsupported)
+	}
+
+	rejoinPolicy := conf.RejoinPolicy
+	if rejoinPolicy == "" {
+		rejoinPolicy = DefaultRejoinPolicy
+	}
+
+	rejoinDelay := conf.RejoinDelay
+	if rejoinDelay <= 0 {
+		rejoinDelay = DefaultRejoinDelay
+	}
+
+	rejoinMaxDelay := conf.RejoinMaxDelay
+	if rejoinMaxDelay <= 0 {
+		rejoinMaxDelay = DefaultRejoinMaxDelay
+	}
+
+	if rejoinMaxDelay < rejoinDelay {
+		rejoinMaxDelay = rejoinDelay
+	}
+
+	caseMapping := NewServerFeatures().CaseMapping
+
+	rejoinPolicies := map[string]RejoinPolicy{}
+	for name, policy := range conf.ChannelRejoinPolicies {
+		rejoinPolicies[caseMapping.Fold(name)] = policy
+	}
+
+	channelKeys := map[string]string{}
+	for name, key := range conf.ChannelKeys {
+		channelKeys[caseMapping.Fold(name)] = key
+	}
+
 	channels := map[string]*Channel{}
 
-	for _, channel := range conf.Channels {
-		channels[channel] = NewChannel(channel)
+	for _, name := range conf.Channels {
+		key := caseMapping.Fold(name)
+
+		channel := NewChannel(name)
+		channel.Key = channelKeys[key]
+		channel.RejoinPolicy = rejoinPolicy
+		channel.rejoin = backoff{min: rejoinDelay, max: rejoinMaxDelay}
+
+		if policy, ok := rejoinPolicies[key]; ok {
+			channel.RejoinPolicy = policy
+		}
+
+		if !validRejoinPolicy(channel.RejoinPolicy) {
+			return nil, fmt.Errorf("%w: %q for %s", ErrInvalidRejoinPolicy, channel.RejoinPolicy, name)
+		}
+
+		channels[key] = channel
+	}
+
+	if len(conf.Nicks) == 0 {
+		return nil, errors.New("at least one nick is required")
+	}
+
+	nickRegainInterval := conf.NickRegainInterval
+	if nickRegainInterval <= 0 {
+		nickRegainInterval = DefaultNickRegainInterval
+	}
+
+	pingInterval := conf.PingInterval
+	if pingInterval <= 0 {
+		pingInterval = DefaultPingInterval
+	}
+
+	pingTimeout := conf.PingTimeout
+	if pingTimeout <= 0 {
+		pingTimeout = DefaultPingTimeout
+	}
+
+	saslTimeout := conf.SASLTimeout
+	if saslTimeout <= 0 {
+		saslTimeout = DefaultSASLTimeout
+	}
+
+	caps := newCapabilityNegotiator(conf.Capabilities)
+
+	if conf.SASLMechanism != "" {
+		caps.want("sasl")
 	}
 
 	return &Connection{
 		Name: conf.Name,
 		OnConnect: []OnConnectHook{
+			defaultCapabilityFunc,
 			defaultLoginFunc,
+		},
+		OnRegistered: []OnRegisteredHook{
+			defaultSASLRegistrationCheck,
 			defaultJoinFunc,
+			defaultNickRegainFunc,
+			defaultKeepaliveFunc,
+		},
+		OnCapability: []OnCapabilityHook{
+			defaultSASLCapabilityHook,
 		},
 		OnDisconnect: []OnDisconnectHook{
 			cleanupChannels,
+			resetCapabilities,
+			resetSASLStatus,
+			resetNicks,
+			resetKeepalive,
+			resetServerFeatures,
+			resetWaiters,
+			resetDeliveries,
 		},
 		OnCommand: []OnCommandHook{
+			defaultCapabilityHandler,
+			defaultSASLAuthenticateHandler,
+			defaultNickChangeHandler,
 			defaultJoinChannelStatusUpdater,
+			defaultMembershipTracker,
+			defaultUserStateTracker,
+			defaultModeTracker,
+			defaultChannelModeRequester,
+			defaultWhoRequester,
+			defaultTopicChangeHandler,
+			defaultInviteHandler,
+			defaultCTCPResponder,
+			defaultSenderRecorder,
+			defaultDeliveryEchoHandler,
 			defaultPrivmsgHandler,
+			defaultNoticeHandler,
+			defaultReactionHandler,
 			defaultUnknownHandler,
 			defaultPingResponder,
+			defaultPongHandler,
+			defaultServerErrorHandler,
+			defaultCommandWaiterResolver,
 		},
 		OnReply: []OnReplyHook{
 			defaultConnectionStatusUpdater,
+			capabilityRegistrationUpdater,
+			defaultSASLReplyHandler,
+			defaultNickCollisionHandler,
+			defaultServerFeaturesHandler,
+			defaultNickRegainHandler,
+			defaultRegistrationHandler,
 			defaultChannelMemberUpdater,
+			defaultChannelModeReplyHandler,
+			defaultTopicReplyHandler,
+			defaultWhoReplyHandler,
+			defaultJoinFailureHandler,
+			defaultRejoinAfterLoginHandler,
+			defaultDeliveryErrorHandler,
+			defaultReplyWaiterResolver,
 		},
 		OnError: []OnErrorHook{
-			defaultErrorHandlerFunc,
+			defaultErrorLogger,
 		},
 		CommandFactory: map[CommandType]ConnectionCommandFactoryFunc{
 			CommandTypePrivmsg: mentionAndDirectPrivmsgCommand,
 		},
 		Status: ConnectionStatus{
-			StartedAt: time.Now(),
+			StartedAt:    time.Now(),
+			Capabilities: map[string]string{},
+		},
+		server:             conf.Server,
+		useTLS:             conf.UseTLS,
+		tlsConfig:          tlsConfig,
+		channels:           channels,
+		pendingNames:       map[string]map[string]*Nick{},
+		pendingLists:       map[string]map[byte][]string{},
+		nickManager:        NewNickManager(conf.Nicks),
+		nickRegainInterval: nickRegainInterval,
+		pingInterval:       pingInterval,
+		pingTimeout:        pingTimeout,
+		flood:              newFloodControl(conf),
+		ctcp:               newCTCPConfig(conf),
+		inviteAllowlist:    conf.InviteAllowlist,
+		senders:            newSenderCache(),
+		rejoinPolicy:       rejoinPolicy,
+		rejoinBackoff:      backoff{min: rejoinDelay, max: rejoinMaxDelay},
+		features:           NewServerFeatures(),
+		caps:               caps,
+		backoff:            backoff{min: reconnectMinBackoff, max: reconnectMaxBackoff},
+		in:                 make(chan MessageObject, 10),
+		out:                make(chan Command, 100),
+		priority:           make(chan Command, 10),
+		restoreReady:       make(chan struct{}, 1),
+		deliveryEvents:     make(chan deliveryEvent, maxPendingLines),
+		sasl: saslConfig{
+			mechanism: strings.ToUpper(conf.SASLMechanism),
+			account:   conf.SASLAccount,
+			password:  conf.SASLPassword,
+			timeout:   saslTimeout,
 		},
-		server:   conf.Server,
-		useTLS:   conf.UseTLS,
-		channels: channels,
-		nicks:    conf.Nicks,
 		user:     conf.User,
 		realName: conf.RealName,
 		password: conf.Password,
@@ -433,6 +913,10 @@ type backoff struct {
 	n uint64
 }
 
+func (b *backoff) reset() {
+	b.n = 0
+}
+
 func (b *backoff) next() time.Duration {
 	factor := b.factor
 
@@ -0,0 +1,116 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/kyleterry/tenyks/pkg/logger"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestConnection returns a connection that isn't dialed. Commands the
+// hooks send can be read off the returned connection's out channel.
+func newTestConnection(t *testing.T, conf Config) *Connection {
+	t.Helper()
+
+	if conf.Server == "" {
+		conf.Server = "irc.example.com:6667"
+	}
+
+	if len(conf.Nicks) == 0 {
+		conf.Nicks = []string{"tenyks"}
+	}
+
+	conf.Logger = logger.NewStandardLogger("test", logger.StandardLoggerConfig{Out: ioutil.Discard})
+
+	c, err := New(conf)
+	require.NoError(t, err)
+
+	c.out = make(chan Command, 100)
+	c.priority = make(chan Command, 100)
+
+	return c
+}
+
+// drainCommands returns the raw lines for every command currently queued on
+// the connection, priority commands first and then the ones restoring the
+// session.
+func drainCommands(t *testing.T, c *Connection) []string {
+	t.Helper()
+
+	lines := []string{}
+
+	add := func(cmd Command) {
+		line, err := cmd.Encode()
+		require.NoError(t, err)
+
+		lines = append(lines, line)
+	}
+
+	drain := func(queue chan Command) {
+		for {
+			select {
+			case cmd := <-queue:
+				add(cmd)
+			default:
+				return
+			}
+		}
+	}
+
+	drain(c.priority)
+
+	c.Lock()
+	restore := c.restore
+	c.restore = nil
+	c.Unlock()
+
+	for _, cmd := range restore {
+		add(cmd)
+	}
+
+	drain(c.out)
+
+	return lines
+}
+
+// mustDecode decodes and maps a raw message the same way the receive loop
+// does.
+func mustDecode(t *testing.T, c *Connection, raw string) MessageObject {
+	t.Helper()
+
+	mo, err := c.decodeAndMapMessage(raw)
+	require.NoError(t, err)
+
+	return mo
+}
+
+func TestBackoff(t *testing.T) {
+	b := backoff{min: time.Second, max: time.Second * 5}
+
+	require.Equal(t, time.Second, b.next())
+	require.Equal(t, time.Second*2, b.next())
+	require.Equal(t, time.Second*4, b.next())
+	require.Equal(t, time.Second*5, b.next())
+	require.Equal(t, time.Second*5, b.next())
+}
+
+func TestServerErrorReachesOnError(t *testing.T) {
+	c := newTestConnection(t, Config{})
+	require.Len(t, c.OnError, 1)
+
+	var errs []error
+	c.OnError = append(c.OnError, func(_ context.Context, _ *Connection, err error) error {
+		errs = append(errs, err)
+
+		return nil
+	})
+
+	dispatch(t, c, "ERROR :Closing link (Ping timeout)")
+
+	require.Len(t, errs, 1)
+	require.True(t, errors.Is(errs[0], ErrServerClosedLink))
+}
@@ -0,0 +1,191 @@
+package irc
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/kyleterry/tenyks/pkg/logger"
+)
+
+// ctcpDelim wraps CTCP messages inside a PRIVMSG or NOTICE.
+const ctcpDelim = "\x01"
+
+// CTCP commands we know about.
+const (
+	CTCPAction     = "ACTION"
+	CTCPVersion    = "VERSION"
+	CTCPPing       = "PING"
+	CTCPTime       = "TIME"
+	CTCPClientInfo = "CLIENTINFO"
+)
+
+const (
+	// DefaultCTCPVersion is our answer to CTCP VERSION.
+	DefaultCTCPVersion = "tenyks"
+	// DefaultCTCPReplyBurst is how many CTCP queries we answer back to back
+	// before rate limiting kicks in.
+	DefaultCTCPReplyBurst = 3
+	// DefaultCTCPReplyRate is how long it takes to earn back one CTCP reply.
+	DefaultCTCPReplyRate = time.Second * 5
+)
+
+// CTCPMessage is a Client-To-Client Protocol message. CTCP messages are
+// PRIVMSGs (queries) or NOTICEs (replies) with text wrapped in \x01, like
+// "\x01VERSION\x01" or "\x01ACTION waves\x01".
+type CTCPMessage struct {
+	Command string
+	Params  string
+}
+
+// ParseCTCP returns the CTCP message in text, if there is one. The closing
+// \x01 is optional since some clients leave it off.
+func ParseCTCP(text string) (CTCPMessage, bool) {
+	if !strings.HasPrefix(text, ctcpDelim) {
+		return CTCPMessage{}, false
+	}
+
+	text = strings.TrimSuffix(text[len(ctcpDelim):], ctcpDelim)
+
+	command, params := text, ""
+	if i := strings.IndexByte(text, ' '); i != -1 {
+		command, params = text[:i], text[i+1:]
+	}
+
+	if command == "" {
+		return CTCPMessage{}, false
+	}
+
+	return CTCPMessage{Command: strings.ToUpper(command), Params: params}, true
+}
+
+// String returns the message wrapped in \x01, ready to be sent.
+func (m CTCPMessage) String() string {
+	if m.Params == "" {
+		return ctcpDelim + m.Command + ctcpDelim
+	}
+
+	return ctcpDelim + m.Command + " " + m.Params + ctcpDelim
+}
+
+// ctcpConfig controls our automatic answers to CTCP queries.
+type ctcpConfig struct {
+	version string
+	ignore  map[string]bool
+	// limit is shared by every query so a flood of them from many nicks
+	// can't get us disconnected. Replies are off when it's nil.
+	limit *tokenBucket
+}
+
+func newCTCPConfig(conf Config) ctcpConfig {
+	cc := ctcpConfig{
+		version: conf.CTCPVersion,
+		ignore:  map[string]bool{},
+	}
+
+	if cc.version == "" {
+		cc.version = DefaultCTCPVersion
+	}
+
+	for _, command := range conf.CTCPIgnore {
+		cc.ignore[strings.ToUpper(command)] = true
+	}
+
+	rate := conf.CTCPReplyRate
+	if rate == 0 {
+		rate = DefaultCTCPReplyRate
+	}
+
+	if rate > 0 {
+		cc.limit = newTokenBucket(DefaultCTCPReplyBurst, rate, time.Now())
+	}
+
+	return cc
+}
+
+// answers returns the queries we reply to.
+func (cc ctcpConfig) answers() []string {
+	answers := []string{}
+
+	for _, command := range []string{CTCPClientInfo, CTCPPing, CTCPTime, CTCPVersion} {
+		if !cc.ignore[command] {
+			answers = append(answers, command)
+		}
+	}
+
+	return answers
+}
+
+// reply returns our answer to query, or false if we don't answer it.
+func (cc ctcpConfig) reply(query CTCPMessage, now time.Time) (CTCPMessage, bool) {
+	if cc.ignore[query.Command] {
+		return CTCPMessage{}, false
+	}
+
+	answer := CTCPMessage{Command: query.Command}
+
+	switch query.Command {
+	case CTCPVersion:
+		answer.Params = cc.version
+	case CTCPPing:
+		answer.Params = query.Params
+	case CTCPTime:
+		answer.Params = now.Format(time.RFC1123Z)
+	case CTCPClientInfo:
+		answer.Params = strings.Join(append([]string{CTCPAction}, cc.answers()...), " ")
+	default:
+		return CTCPMessage{}, false
+	}
+
+	return answer, true
+}
+
+// defaultCTCPResponder answers VERSION, PING, TIME and CLIENTINFO queries
+// with a NOTICE. Replies are rate limited and queries sent to a channel are
+// answered privately.
+func defaultCTCPResponder(ctx context.Context, c *Connection, command Command) error {
+	cmd, ok := command.(*PrivmsgCommand)
+	if !ok {
+		return nil
+	}
+
+	query, ok := cmd.CTCP()
+	if !ok || query.Command == CTCPAction {
+		return nil
+	}
+
+	nick := prefixNick(cmd.Message())
+	if nick == "" || c.isSelf(nick) {
+		return nil
+	}
+
+	now := time.Now()
+
+	answer, ok := c.ctcp.reply(query, now)
+	if !ok {
+		c.log.Debug("ignoring CTCP query",
+			logger.Param{Key: "command", Value: query.Command},
+			logger.Param{Key: "from", Value: nick})
+
+		return nil
+	}
+
+	var allowed bool
+
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		if conn.ctcp.limit != nil && conn.ctcp.limit.wait(1, now) == 0 {
+			conn.ctcp.limit.take(1, now)
+			allowed = true
+		}
+	})
+
+	if !allowed {
+		c.log.Debug("dropping CTCP reply",
+			logger.Param{Key: "command", Value: query.Command},
+			logger.Param{Key: "from", Value: nick})
+
+		return nil
+	}
+
+	return c.enqueueRestoreCommand(NewCTCPReplyCommand(nick, answer))
+}
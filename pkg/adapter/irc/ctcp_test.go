@@ -0,0 +1,97 @@
+package irc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kyleterry/tenyks/pkg/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCTCP(t *testing.T) {
+	ctcp, ok := ParseCTCP("\x01ACTION waves at everyone\x01")
+	require.True(t, ok)
+	require.Equal(t, CTCPMessage{Command: CTCPAction, Params: "waves at everyone"}, ctcp)
+
+	// the closing delimiter is optional
+	ctcp, ok = ParseCTCP("\x01version")
+	require.True(t, ok)
+	require.Equal(t, CTCPMessage{Command: CTCPVersion}, ctcp)
+
+	_, ok = ParseCTCP("just chatting")
+	require.False(t, ok)
+
+	_, ok = ParseCTCP("\x01\x01")
+	require.False(t, ok)
+
+	require.Equal(t, "\x01PING 12345\x01", CTCPMessage{Command: CTCPPing, Params: "12345"}.String())
+}
+
+func TestCTCPResponder(t *testing.T) {
+	c := newTestConnection(t, Config{
+		CTCPVersion: "tenyks v2",
+		CTCPIgnore:  []string{"time"},
+	})
+	c.Status.CurrentNick = "tenyks"
+
+	dispatch(t, c, ":alice!~alice@example.com PRIVMSG tenyks :\x01VERSION\x01")
+	dispatch(t, c, ":alice!~alice@example.com PRIVMSG #tenyks :\x01PING 1600000000\x01")
+	dispatch(t, c, ":alice!~alice@example.com PRIVMSG tenyks :\x01TIME\x01")
+	dispatch(t, c, ":alice!~alice@example.com PRIVMSG tenyks :\x01CLIENTINFO\x01")
+
+	require.Equal(t, []string{
+		"NOTICE alice :\x01VERSION tenyks v2\x01\r\n",
+		"NOTICE alice :\x01PING 1600000000\x01\r\n",
+		"NOTICE alice :\x01CLIENTINFO ACTION CLIENTINFO PING VERSION\x01\r\n",
+	}, drainCommands(t, c))
+
+	// the burst is spent, so this one is dropped
+	dispatch(t, c, ":bob!~bob@example.com PRIVMSG tenyks :\x01VERSION\x01")
+	require.Empty(t, drainCommands(t, c))
+
+	t.Run("disabled", func(t *testing.T) {
+		c := newTestConnection(t, Config{CTCPReplyRate: -1})
+
+		dispatch(t, c, ":alice!~alice@example.com PRIVMSG tenyks :\x01VERSION\x01")
+		require.Empty(t, drainCommands(t, c))
+	})
+}
+
+func TestCTCPAction(t *testing.T) {
+	c := newTestConnection(t, Config{})
+	c.Status.CurrentNick = "tenyks"
+
+	msgs := []*message.ChatMessage{}
+	c.RegisterMessageHandler(func(msg message.Message) {
+		msgs = append(msgs, msg.(*message.ChatMessage))
+	})
+
+	dispatch(t, c, ":alice!~alice@example.com PRIVMSG #tenyks :\x01ACTION waves\x01")
+	dispatch(t, c, ":alice!~alice@example.com PRIVMSG #tenyks :\x01VERSION\x01")
+
+	require.Len(t, msgs, 1)
+	require.True(t, msgs[0].Action)
+	require.Equal(t, "waves", msgs[0].Content)
+
+	decoder := tenyksChatMessageDecoder{budget: func(string) int { return 20 }}
+	cmds, err := decoder.Decode(&message.ChatMessage{
+		DestinationPath: "/irc/test/#tenyks",
+		Content:         "waves back at alice",
+		Action:          true,
+		Timestamp:       time.Now(),
+	})
+	require.NoError(t, err)
+
+	lines := []string{}
+	for _, cmd := range cmds {
+		line, err := cmd.Encode()
+		require.NoError(t, err)
+
+		lines = append(lines, line)
+	}
+
+	require.Equal(t, []string{
+		"PRIVMSG #tenyks :\x01ACTION waves back\x01\r\n",
+		"PRIVMSG #tenyks :\x01ACTION at alice\x01\r\n",
+	}, lines)
+}
@@ -0,0 +1,419 @@
+package irc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kyleterry/tenyks/pkg/logger"
+	"github.com/kyleterry/tenyks/pkg/message"
+)
+
+const (
+	// DeliveryStatusEchoed means the server echoed the message back to us
+	// with echo-message, so it reached its target.
+	DeliveryStatusEchoed = "echoed"
+	// DeliveryStatusWritten means the message was written to the socket.
+	// Without echo-message that's all we know.
+	DeliveryStatusWritten = "written"
+	// DeliveryStatusFailed means the message won't arrive because the server
+	// refused it or flood control dropped it. The error attribute says why.
+	DeliveryStatusFailed = "failed"
+	// DeliveryStatusUnknown means we gave up waiting for the echo because
+	// too many other lines were waiting too.
+	DeliveryStatusUnknown = "unknown"
+	// maxPendingLines is how many sent lines we wait on before giving up on
+	// the oldest.
+	maxPendingLines = 256
+)
+
+// delivery is a chat message a service wants to know the fate of.
+type delivery struct {
+	requestID string
+	target    string
+	// echo is true while we wait for echoes instead of writes.
+	echo bool
+	// lines is how many lines haven't been confirmed yet.
+	lines int
+	// messageID is the msgid of the first line the server echoed.
+	messageID string
+	// reason is why the delivery failed, if it did.
+	reason string
+	// handler is where the sender wants the delivery event. It goes to
+	// every message handler when it's nil.
+	handler message.HandlerFunc
+}
+
+// deliveryEvent is a delivery event on its way to the service that sent the
+// message.
+type deliveryEvent struct {
+	handler message.HandlerFunc
+	event   *message.EventMessage
+}
+
+// pendingLine is one line of a delivery that hasn't been confirmed.
+type pendingLine struct {
+	delivery *delivery
+	msg      *Message
+	label    string
+	written  bool
+}
+
+// echoedBy returns true if msg is the server echoing the line back. The
+// server may change the case of the target.
+func (l *pendingLine) echoedBy(cm CaseMapping, msg *Message) bool {
+	if l.msg.Command != msg.Command || len(l.msg.Params) == 0 || len(msg.Params) == 0 {
+		return false
+	}
+
+	return cm.Equal(l.msg.Params[0], msg.Params[0]) && l.msg.Trail == msg.Trail
+}
+
+// trackDelivery waits on cmds, the lines of a chat message to target, and
+// tells services when they're delivered. Lines are labeled when the server
+// supports labeled-response so their echoes can be told apart from
+// identical messages. It must be called before the commands are enqueued.
+// The delivery event goes to the handler set on ctx with
+// message.WithDeliveryHandler, if there is one.
+func (c *Connection) trackDelivery(ctx context.Context, requestID, target string, cmds []Command) *delivery {
+	echo := c.HasCapability("echo-message")
+	labeled := echo && c.HasCapability("labeled-response")
+
+	d := &delivery{
+		requestID: requestID,
+		target:    target,
+		echo:      echo,
+		lines:     len(cmds),
+	}
+
+	if h, ok := message.DeliveryHandler(ctx); ok {
+		d.handler = h
+	}
+
+	var unknown []*delivery
+
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		for _, cmd := range cmds {
+			line := &pendingLine{delivery: d, msg: cmd.Message()}
+
+			if labeled {
+				conn.labels++
+				line.label = fmt.Sprintf("tenyks-%d", conn.labels)
+				line.msg.SetTag("label", line.label)
+			}
+
+			conn.deliveries = append(conn.deliveries, line)
+		}
+
+		for len(conn.deliveries) > maxPendingLines {
+			oldest := conn.deliveries[0].delivery
+			conn.forgetDelivery(oldest)
+			unknown = append(unknown, oldest)
+		}
+	})
+
+	for _, d := range unknown {
+		c.dispatchDelivery(d, DeliveryStatusUnknown)
+	}
+
+	return d
+}
+
+// confirmLine forgets the line at i and returns its delivery if that was
+// the last line. The caller must hold the connection's lock.
+func (c *Connection) confirmLine(i int) *delivery {
+	d := c.deliveries[i].delivery
+	c.deliveries = append(c.deliveries[:i], c.deliveries[i+1:]...)
+
+	d.lines--
+	if d.lines > 0 {
+		return nil
+	}
+
+	return d
+}
+
+// forgetDelivery stops waiting on every line of d. The caller must hold the
+// connection's lock.
+func (c *Connection) forgetDelivery(d *delivery) {
+	lines := c.deliveries[:0]
+
+	for _, line := range c.deliveries {
+		if line.delivery != d {
+			lines = append(lines, line)
+		}
+	}
+
+	c.deliveries = lines
+	d.lines = 0
+}
+
+// failDelivery stops waiting on d and reports it as failed because of
+// reason, unless it was already reported.
+func (c *Connection) failDelivery(d *delivery, reason string) {
+	c.Lock()
+	pending := d.lines > 0
+	c.forgetDelivery(d)
+	c.Unlock()
+
+	if !pending {
+		return
+	}
+
+	d.reason = reason
+	c.dispatchDelivery(d, DeliveryStatusFailed)
+}
+
+// dispatchDelivery sends the delivery event for d to the service that sent
+// it, or to every service if we don't know which one that is.
+func (c *Connection) dispatchDelivery(d *delivery, status string) {
+	event := &message.EventMessage{
+		Kind:       message.EventKindDelivery,
+		TargetPath: c.targetPath(d.target),
+		Attributes: map[string]string{
+			"requestId": d.requestID,
+			"status":    status,
+		},
+		Timestamp: time.Now(),
+	}
+
+	if d.messageID != "" {
+		event.Attributes["messageId"] = d.messageID
+	}
+
+	if d.reason != "" {
+		event.Attributes["error"] = d.reason
+	}
+
+	if d.handler != nil {
+		select {
+		case c.deliveryEvents <- deliveryEvent{handler: d.handler, event: event}:
+		default:
+			c.log.Error("dropping delivery event",
+				logger.Param{Key: "connection", Value: c.Name},
+				logger.Param{Key: "requestId", Value: d.requestID},
+				logger.Param{Key: "status", Value: status})
+		}
+
+		return
+	}
+
+	c.dispatchMessage(event)
+}
+
+// dispatchDeliveryEvents hands delivery events to the services that sent
+// the messages until ctx is done. It runs on its own goroutine because
+// deliveries are reported from the send loop and the dispatcher, which a
+// slow service must never hold up.
+func (c *Connection) dispatchDeliveryEvents(ctx context.Context) {
+	for {
+		select {
+		case e := <-c.deliveryEvents:
+			e.handler(e.event)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// messageWritten is called by the send loop after cmd is written to the
+// socket. Deliveries that don't wait for echoes are done once all their
+// lines are written.
+func (c *Connection) messageWritten(cmd Command) {
+	var done *delivery
+
+	c.Lock()
+	for i, line := range c.deliveries {
+		if line.msg != cmd.Message() {
+			continue
+		}
+
+		line.written = true
+
+		if !line.delivery.echo {
+			done = c.confirmLine(i)
+		}
+
+		break
+	}
+	c.Unlock()
+
+	if done != nil {
+		c.dispatchDelivery(done, DeliveryStatusWritten)
+	}
+}
+
+// messageDropped is called by the send loop when flood control drops cmd.
+// The delivery it belongs to fails, even if other lines of it were sent.
+func (c *Connection) messageDropped(cmd Command) {
+	var failed *delivery
+
+	c.RLock()
+	for _, line := range c.deliveries {
+		if line.msg == cmd.Message() {
+			failed = line.delivery
+
+			break
+		}
+	}
+	c.RUnlock()
+
+	if failed != nil {
+		c.failDelivery(failed, "dropped by flood control")
+	}
+}
+
+// defaultDeliveryEchoHandler matches the messages echo-message sends back to
+// the lines we're waiting on, by label if the server supports
+// labeled-response and by content otherwise.
+func defaultDeliveryEchoHandler(ctx context.Context, c *Connection, command Command) error {
+	switch command.(type) {
+	case *PrivmsgCommand, *NoticeCommand, *TagmsgCommand:
+	default:
+		return nil
+	}
+
+	msg := command.Message()
+	label, labeled := msg.Tag("label")
+
+	var done *delivery
+
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		if !conn.isCurrentNick(prefixNick(msg)) {
+			return
+		}
+
+		for i, line := range conn.deliveries {
+			if !line.delivery.echo {
+				continue
+			}
+
+			// labeled lines only match their own echo
+			matched := line.echoedBy(conn.features.CaseMapping, msg)
+			if labeled || line.label != "" {
+				matched = line.label == label
+			}
+
+			if !matched {
+				continue
+			}
+
+			if line.delivery.messageID == "" {
+				line.delivery.messageID = messageID(msg)
+			}
+
+			done = conn.confirmLine(i)
+
+			return
+		}
+	})
+
+	if done != nil {
+		c.dispatchDelivery(done, DeliveryStatusEchoed)
+	}
+
+	return nil
+}
+
+// defaultDeliveryErrorHandler fails deliveries the server refuses. With
+// labeled-response the error numeric carries the label of the line it's
+// about. Otherwise ERR_CANNOTSENDTOCHAN and ERR_NOSUCHNICK fail the oldest
+// line to their target that's still waiting on its echo.
+func defaultDeliveryErrorHandler(ctx context.Context, c *Connection, reply Reply) error {
+	msg := reply.Message()
+
+	if !isErrorNumeric(msg.Command) {
+		return nil
+	}
+
+	label, labeled := msg.Tag("label")
+
+	var target string
+
+	if !labeled {
+		switch r := reply.(type) {
+		case *ErrCannotSendToChanReply:
+			if r.Validate() != nil {
+				return nil
+			}
+
+			target = r.Channel()
+		case *ErrNoSuchNickReply:
+			target = r.Nick()
+		default:
+			return nil
+		}
+	}
+
+	var failed *delivery
+
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		for _, line := range conn.deliveries {
+			if !line.delivery.echo {
+				continue
+			}
+
+			matched := line.label == label
+			if !labeled {
+				matched = line.label == "" && len(line.msg.Params) > 0 &&
+					conn.features.CaseMapping.Equal(line.msg.Params[0], target)
+			}
+
+			if matched {
+				failed = line.delivery
+				conn.forgetDelivery(failed)
+
+				return
+			}
+		}
+	})
+
+	if failed == nil {
+		return nil
+	}
+
+	failed.reason = msg.Trail
+	if failed.reason == "" {
+		failed.reason = msg.Command
+	}
+
+	c.dispatchDelivery(failed, DeliveryStatusFailed)
+
+	return nil
+}
+
+// isErrorNumeric returns true for the 400 and 500 numerics servers use for
+// errors.
+func isErrorNumeric(command string) bool {
+	return len(command) == 3 && (command[0] == '4' || command[0] == '5')
+}
+
+// resetDeliveries gives up on echoes for lines written before the session
+// ended and reports their deliveries as written. Lines that weren't written
+// yet are sent on the next session.
+func resetDeliveries(ctx context.Context, c *Connection) error {
+	var written []*delivery
+
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		for i := 0; i < len(conn.deliveries); {
+			line := conn.deliveries[i]
+			if !line.written {
+				i++
+
+				continue
+			}
+
+			line.delivery.echo = false
+
+			if d := conn.confirmLine(i); d != nil {
+				written = append(written, d)
+			}
+		}
+	})
+
+	for _, d := range written {
+		c.dispatchDelivery(d, DeliveryStatusWritten)
+	}
+
+	return nil
+}
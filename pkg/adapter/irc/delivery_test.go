@@ -0,0 +1,308 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kyleterry/tenyks/pkg/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelivery(t *testing.T) {
+	ctx := context.Background()
+
+	c := newTestConnection(t, Config{Name: "test"})
+	c.Status.CurrentNick = "tenyks"
+
+	msgs := []message.Message{}
+	c.RegisterMessageHandler(func(msg message.Message) {
+		msgs = append(msgs, msg)
+	})
+
+	// send queues msg and returns its lines as the send loop would write
+	// them.
+	send := func(requestID, content string) []Command {
+		t.Helper()
+
+		require.NoError(t, c.SendAsync(ctx, &message.ChatMessage{
+			DestinationPath: "/irc/test/#tenyks",
+			Content:         content,
+			RequestID:       requestID,
+			Timestamp:       time.Now(),
+		}))
+
+		cmds := []Command{}
+		for len(c.out) > 0 {
+			cmds = append(cmds, <-c.out)
+		}
+
+		return cmds
+	}
+
+	lastEvent := func() *message.EventMessage {
+		t.Helper()
+
+		require.NotEmpty(t, msgs)
+		require.IsType(t, &message.EventMessage{}, msgs[len(msgs)-1])
+
+		return msgs[len(msgs)-1].(*message.EventMessage)
+	}
+
+	t.Run("written", func(t *testing.T) {
+		cmds := send("req-1", "hi")
+		require.Len(t, cmds, 1)
+
+		c.messageWritten(cmds[0])
+
+		event := lastEvent()
+		require.Equal(t, message.EventKindDelivery, event.Kind)
+		require.Equal(t, "/irc/test/#tenyks", event.TargetPath)
+		require.Equal(t, map[string]string{"requestId": "req-1", "status": DeliveryStatusWritten}, event.Attributes)
+		require.Empty(t, c.deliveries)
+	})
+
+	c.Status.Capabilities["echo-message"] = ""
+	msgs = nil
+
+	t.Run("echoed", func(t *testing.T) {
+		cmds := send("req-2", "hello")
+		c.messageWritten(cmds[0])
+		require.Empty(t, msgs)
+
+		// someone else saying the same thing isn't our echo
+		dispatch(t, c, "@msgid=other :alice!~alice@example.com PRIVMSG #tenyks :hello")
+		require.Len(t, msgs, 1)
+		require.IsType(t, &message.ChatMessage{}, msgs[0])
+
+		dispatch(t, c, "@msgid=abc :tenyks!~tenyks@example.com PRIVMSG #Tenyks :hello")
+
+		// the echo itself isn't passed on as chat
+		require.Len(t, msgs, 2)
+		require.Equal(t, map[string]string{
+			"requestId": "req-2",
+			"status":    DeliveryStatusEchoed,
+			"messageId": "abc",
+		}, lastEvent().Attributes)
+	})
+
+	c.Status.Capabilities["labeled-response"] = ""
+	msgs = nil
+
+	t.Run("labeled", func(t *testing.T) {
+		cmds := send("req-3", "hello")
+
+		line, err := cmds[0].Encode()
+		require.NoError(t, err)
+		require.Equal(t, "@label=tenyks-1 PRIVMSG #tenyks :hello\r\n", line)
+
+		// an unlabeled echo of the same text belongs to someone else
+		dispatch(t, c, "@msgid=abc :tenyks!~tenyks@example.com PRIVMSG #tenyks :hello")
+		require.Empty(t, msgs)
+
+		dispatch(t, c, "@label=tenyks-1;msgid=def :tenyks!~tenyks@example.com PRIVMSG #tenyks :hello")
+		require.Equal(t, "def", lastEvent().Attributes["messageId"])
+	})
+
+	msgs = nil
+
+	t.Run("disconnect", func(t *testing.T) {
+		written := send("req-4", "one")
+		send("req-5", "two")
+
+		c.messageWritten(written[0])
+		require.NoError(t, resetDeliveries(ctx, c))
+
+		require.Len(t, msgs, 1)
+		require.Equal(t, "req-4", lastEvent().Attributes["requestId"])
+		require.Equal(t, DeliveryStatusWritten, lastEvent().Attributes["status"])
+		require.Len(t, c.deliveries, 1)
+	})
+}
+
+func TestDeliveryFailures(t *testing.T) {
+	ctx := context.Background()
+
+	c := newTestConnection(t, Config{Name: "test"})
+	c.Status.CurrentNick = "tenyks"
+	c.Status.Capabilities["echo-message"] = ""
+	c.Status.Capabilities["labeled-response"] = ""
+
+	events := []*message.EventMessage{}
+	c.RegisterMessageHandler(func(msg message.Message) {
+		events = append(events, msg.(*message.EventMessage))
+	})
+
+	send := func(requestID string) Command {
+		t.Helper()
+
+		require.NoError(t, c.SendAsync(ctx, &message.ChatMessage{
+			DestinationPath: "/irc/test/#tenyks",
+			Content:         "hello",
+			RequestID:       requestID,
+			Timestamp:       time.Now(),
+		}))
+
+		cmd := <-c.out
+		c.messageWritten(cmd)
+
+		return cmd
+	}
+
+	t.Run("refused", func(t *testing.T) {
+		label, _ := send("req-1").Message().Tag("label")
+
+		dispatch(t, c, "@label="+label+" :irc.test 404 tenyks #tenyks :Cannot send to channel")
+
+		require.Len(t, events, 1)
+		require.Equal(t, map[string]string{
+			"requestId": "req-1",
+			"status":    DeliveryStatusFailed,
+			"error":     "Cannot send to channel",
+		}, events[0].Attributes)
+		require.Empty(t, c.deliveries)
+	})
+
+	delete(c.Status.Capabilities, "labeled-response")
+	events = nil
+
+	t.Run("refused without labels", func(t *testing.T) {
+		send("req-2")
+
+		dispatch(t, c, ":irc.test 404 tenyks #other :Cannot send to channel")
+		require.Empty(t, events)
+
+		dispatch(t, c, ":irc.test 404 tenyks #Tenyks :Cannot send to channel")
+		require.Len(t, events, 1)
+		require.Equal(t, DeliveryStatusFailed, events[0].Attributes["status"])
+	})
+
+	events = nil
+
+	t.Run("dropped by flood control", func(t *testing.T) {
+		require.NoError(t, c.SendAsync(ctx, &message.ChatMessage{
+			DestinationPath: "/irc/test/#tenyks",
+			Content:         "hello",
+			RequestID:       "req-3",
+			Timestamp:       time.Now(),
+		}))
+
+		c.messageDropped(<-c.out)
+
+		require.Len(t, events, 1)
+		require.Equal(t, DeliveryStatusFailed, events[0].Attributes["status"])
+		require.Equal(t, "dropped by flood control", events[0].Attributes["error"])
+	})
+
+	events = nil
+
+	t.Run("too many waiting", func(t *testing.T) {
+		for i := 0; i <= maxPendingLines; i++ {
+			send(fmt.Sprintf("req-%d", i+4))
+		}
+
+		require.Len(t, events, 1)
+		require.Equal(t, "req-4", events[0].Attributes["requestId"])
+		require.Equal(t, DeliveryStatusUnknown, events[0].Attributes["status"])
+		require.Len(t, c.deliveries, maxPendingLines)
+	})
+
+	t.Run("not queued", func(t *testing.T) {
+		c := newTestConnection(t, Config{Name: "test"})
+		c.out = make(chan Command, 1)
+
+		events := []*message.EventMessage{}
+		c.RegisterMessageHandler(func(msg message.Message) {
+			events = append(events, msg.(*message.EventMessage))
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+		defer cancel()
+
+		// the second line doesn't fit on the queue
+		err := c.SendAsync(ctx, &message.ChatMessage{
+			DestinationPath: "/irc/test/#tenyks",
+			Content:         "hello\nworld",
+			RequestID:       "req-queue",
+			Timestamp:       time.Now(),
+		})
+		require.True(t, errors.Is(err, context.DeadlineExceeded))
+
+		require.Len(t, events, 1)
+		require.Equal(t, "req-queue", events[0].Attributes["requestId"])
+		require.Equal(t, DeliveryStatusFailed, events[0].Attributes["status"])
+		require.Empty(t, c.deliveries)
+	})
+}
+
+func TestDeliveryHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := newTestConnection(t, Config{Name: "test"})
+	c.Status.CurrentNick = "tenyks"
+
+	go c.dispatchDeliveryEvents(ctx)
+
+	broadcast := []message.Message{}
+	c.RegisterMessageHandler(func(msg message.Message) {
+		broadcast = append(broadcast, msg)
+	})
+
+	send := func(ctx context.Context, requestID string) {
+		t.Helper()
+
+		require.NoError(t, c.SendAsync(ctx, &message.ChatMessage{
+			DestinationPath: "/irc/test/#tenyks",
+			Content:         "hi",
+			RequestID:       requestID,
+			Timestamp:       time.Now(),
+		}))
+
+		c.messageWritten(<-c.out)
+	}
+
+	sender := make(chan message.Message, 1)
+	send(message.WithDeliveryHandler(ctx, func(msg message.Message) {
+		sender <- msg
+	}), "req-1")
+
+	select {
+	case msg := <-sender:
+		require.Equal(t, "req-1", msg.(*message.EventMessage).Attributes["requestId"])
+	case <-time.After(time.Second):
+		t.Fatal("delivery event never reached the sender")
+	}
+
+	require.Empty(t, broadcast)
+
+	t.Run("blocked", func(t *testing.T) {
+		unblock := make(chan struct{})
+		defer close(unblock)
+
+		blocked := message.WithDeliveryHandler(ctx, func(message.Message) {
+			<-unblock
+		})
+
+		// a handler that never returns doesn't hold up the send loop, its
+		// events are dropped once the queue is full
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+
+			for i := 0; i < cap(c.deliveryEvents)*2; i++ {
+				send(blocked, fmt.Sprintf("req-blocked-%d", i))
+			}
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second * 5):
+			t.Fatal("reporting deliveries blocked on the handler")
+		}
+
+		require.Empty(t, c.deliveries)
+	})
+}
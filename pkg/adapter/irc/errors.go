@@ -3,3 +3,36 @@ package irc
 import "errors"
 
 var ParameterCountValidationError = errors.New("invalid number of parameters")
+
+var (
+	// ErrSASLFailed is returned through the OnError hooks when SASL
+	// authentication doesn't succeed.
+	ErrSASLFailed = errors.New("SASL authentication failed")
+	// ErrSASLMechanismUnsupported is returned when the configured SASL
+	// mechanism isn't implemented or the server doesn't offer it.
+	ErrSASLMechanismUnsupported = errors.New("SASL mechanism not supported")
+)
+
+// ErrInvalidRejoinPolicy is returned by New when a channel is configured with
+// a rejoin policy that doesn't exist.
+var ErrInvalidRejoinPolicy = errors.New("invalid rejoin policy")
+
+// ErrServerClosedLink is the reason a session ends when the server sends an
+// ERROR command.
+var ErrServerClosedLink = errors.New("server closed the link")
+
+// ErrPingTimeout is the reason a session ends when the server doesn't answer
+// our PING in time.
+var ErrPingTimeout = errors.New("ping timeout")
+
+var (
+	// ErrRequestFailed is wrapped by the ReplyError SendAndWait returns when
+	// the server answers with an error numeric.
+	ErrRequestFailed = errors.New("server refused the request")
+	// ErrNoReplyExpected is returned by SendAndWait for commands it doesn't
+	// know how to match replies to.
+	ErrNoReplyExpected = errors.New("no reply expected for command")
+	// ErrDisconnected is returned by SendAndWait when the session ends before
+	// the server answers.
+	ErrDisconnected = errors.New("disconnected before the server answered")
+)
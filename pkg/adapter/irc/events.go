@@ -3,52 +3,92 @@ package irc
 import (
 	"context"
 	"fmt"
-	"io"
 	"strings"
 
 	"github.com/kyleterry/tenyks/pkg/logger"
+	"github.com/kyleterry/tenyks/pkg/message"
 )
 
 func defaultLoginFunc(ctx context.Context, c *Connection) error {
-	// TODO figure out how to create a blocking and async message sending API.
-	// blocking can beused for sending messages (like PASS) and checking if there
-	// is an error coming back from the server allowing us to respond to it immediately.
-
 	if c.password != "" {
 		passCmd := NewPassCommand(c.password)
-		c.out <- passCmd
+		if err := c.EnqueueCommand(passCmd); err != nil {
+			return err
+		}
 	}
 
 	// TODO support MODE bitmasks: https://tools.ietf.org/html/rfc2812#page-11
 	userCmd := NewUserCommand(c.user, 0, c.realName)
-	c.out <- userCmd
+	if err := c.EnqueueCommand(userCmd); err != nil {
+		return err
+	}
 
-	nickCmd := NewNickCommand(c.nicks[0])
-	c.out <- nickCmd
-	c.Status.CurrentNick = c.nicks[0]
+	// the server confirms which nick we got in RPL_WELCOME
+	nickCmd := NewNickCommand(c.nickManager.Next())
+	if err := c.EnqueueCommand(nickCmd); err != nil {
+		return err
+	}
 
 	return nil
 }
 
+// defaultJoinFunc joins the configured channels. It runs once registration
+// is complete since servers won't accept a JOIN before that, and by then
+// RPL_ISUPPORT told us how many channels fit in one JOIN. After a reconnect
+// this rejoins every channel before queued messages are sent.
 func defaultJoinFunc(ctx context.Context, c *Connection) error {
-	if len(c.channels) > 0 {
-		channels := []string{}
-		for channel := range c.channels {
+	channels := []*Channel{}
+
+	c.WithReadLock(ctx, func(conn *Connection) {
+		for _, channel := range conn.channels {
 			channels = append(channels, channel)
 		}
+	})
 
-		c.out <- NewJoinCommand(channels...)
+	for _, cmd := range c.joinCommands(channels) {
+		if err := c.enqueueRestoreCommand(cmd); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
 func defaultConnectionStatusUpdater(ctx context.Context, c *Connection, reply Reply) error {
-	switch reply.(type) {
+	switch r := reply.(type) {
 	case *WelcomeReply:
+		nick := r.Nick()
+
 		c.WithWriteLock(ctx, func(conn *Connection) {
 			conn.Status.Connected = true
+			conn.Status.CurrentNick = nick
 		})
+
+		c.nickManager.Confirm(nick)
+	}
+
+	return nil
+}
+
+// defaultRegistrationHandler runs the OnRegistered hooks once the server
+// has sent the MOTD and then releases the send queue. The MOTD comes after
+// RPL_ISUPPORT, so the hooks know the server's limits. Releasing the queue
+// last means anything the hooks send, like rejoining channels, goes out
+// before the backlog. A MOTD we ask for later is ignored.
+func defaultRegistrationHandler(ctx context.Context, c *Connection, reply Reply) error {
+	switch reply.(type) {
+	case *EndOfMOTDReply, *ErrNoMOTDReply:
+		if c.isRegistered() {
+			return nil
+		}
+
+		defer c.markRegistered(ctx)
+
+		for _, hook := range c.OnRegistered {
+			if err := hook(ctx, c); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
@@ -59,25 +99,33 @@ func defaultPingResponder(ctx context.Context, c *Connection, command Command) e
 	case *PingCommand:
 		pongCmd := NewPongCommand(cmd.Message().Trail)
 
-		c.out <- pongCmd
+		return c.EnqueueCommand(pongCmd)
 	}
 
 	return nil
 }
 
+// defaultJoinChannelStatusUpdater marks a channel as joined when the server
+// confirms our JOIN. The member list starts empty and is filled by the NAMES
+// reply that follows.
 func defaultJoinChannelStatusUpdater(ctx context.Context, c *Connection, command Command) error {
 	switch cmd := command.(type) {
 	case *JoinCommand:
 		msg := cmd.Message()
 
 		if msg.PrefixSection != nil {
-			if msg.PrefixSection.Nick == c.Status.CurrentNick {
-				channelName := cmd.Message().Params[0]
+			if c.isSelf(msg.PrefixSection.Nick) {
+				channelName := cmd.Channel()
 
 				c.WithWriteLock(ctx, func(conn *Connection) {
-					if channel, ok := conn.channels[channelName]; ok {
+					conn.Status.Hostmask = msg.PrefixSection.RawPrefix
+
+					if channel, ok := conn.channel(channelName); ok {
 						channel.Status.Status = ChannelStatusJoined
-						conn.channels[channelName] = channel
+						channel.Status.Message = ""
+						channel.Status.reset()
+						channel.rejoin.reset()
+						channel.rejoinPending = false
 					}
 				})
 			}
@@ -88,31 +136,77 @@ func defaultJoinChannelStatusUpdater(ctx context.Context, c *Connection, command
 }
 
 // defaultChannelMemberUpdater finds RPL_NAMREPLY messages and updates our copy
-// of a channel's member list.
+// of a channel's member list. NAMES can span several replies, so the list is
+// collected until RPL_ENDOFNAMES and then replaces the old one. That way
+// members who left while we weren't looking don't linger when NAMES is
+// requested again.
 func defaultChannelMemberUpdater(ctx context.Context, c *Connection, reply Reply) error {
 	switch r := reply.(type) {
 	case *NamesReply:
+		if err := r.Validate(); err != nil {
+			return err
+		}
+
 		channelName := r.Channel()
 		names := r.Names()
+		features := c.Features()
 
 		c.WithWriteLock(ctx, func(conn *Connection) {
-			if channel, ok := conn.channels[channelName]; ok {
-				for _, name := range names {
-					channel.Status.Nicks[name] = &Nick{Name: name}
+			if _, ok := conn.channel(channelName); !ok {
+				return
+			}
+
+			key := conn.fold(channelName)
+
+			pending, ok := conn.pendingNames[key]
+			if !ok {
+				pending = map[string]*Nick{}
+				conn.pendingNames[key] = pending
+			}
+
+			for _, name := range names {
+				prefixes, nick := features.SplitPrefixes(name)
+				if nick == "" {
+					continue
 				}
 
-				conn.channels[channelName] = channel
+				pending[conn.fold(nick)] = &Nick{
+					Name:  nick,
+					Modes: features.PrefixModesFor(prefixes),
+				}
 			}
 		})
 	case *EndOfNamesReply:
 		members := []string{}
 		channelName := r.Channel()
 
-		c.WithReadLock(ctx, func(conn *Connection) {
-			if channel, ok := conn.channels[channelName]; ok {
-				for nick := range channel.Status.Nicks {
-					members = append(members, nick)
+		c.WithWriteLock(ctx, func(conn *Connection) {
+			key := conn.fold(channelName)
+
+			channel, ok := conn.channel(channelName)
+			if !ok {
+				return
+			}
+
+			if pending, ok := conn.pendingNames[key]; ok {
+				// keep what WHO told us about members that are still here
+				for nickKey, nick := range pending {
+					if old, ok := channel.Status.Nicks[nickKey]; ok {
+						name, modes := nick.Name, nick.Modes
+						*nick = *old
+						nick.Name, nick.Modes = name, modes
+					}
 				}
+
+				channel.Status.Nicks = pending
+				delete(conn.pendingNames, key)
+			} else {
+				// an empty NAMES, which means nobody we can see is in there
+				channel.Status.Nicks = map[string]*Nick{}
+			}
+
+			for _, nick := range channel.Status.Nicks {
+				members = append(members, nick.Name)
 			}
 		})
 
@@ -123,14 +217,29 @@ func defaultChannelMemberUpdater(ctx context.Context, c *Connection, reply Reply
 	return nil
 }
 
-func defaultErrorHandlerFunc(ctx context.Context, c *Connection, err error) error {
-	if err == io.EOF {
-		return c.Close(ctx)
+// defaultServerErrorHandler ends the session when the server sends ERROR,
+// which it does right before closing the link.
+func defaultServerErrorHandler(ctx context.Context, c *Connection, command Command) error {
+	switch cmd := command.(type) {
+	case *ErrorCommand:
+		err := fmt.Errorf("%w: %s", ErrServerClosedLink, cmd.Message().Trail)
+
+		c.dispatchError(ctx, err)
+		c.disconnect(err)
 	}
 
 	return nil
 }
 
+// defaultErrorLogger logs every error passed to the OnError hooks.
+func defaultErrorLogger(ctx context.Context, c *Connection, err error) error {
+	c.log.Error("connection error",
+		logger.Param{Key: "connection", Value: c.Name},
+		logger.Param{Key: "error", Value: err})
+
+	return nil
+}
+
 func defaultUnknownHandler(ctx context.Context, c *Connection, command Command) error {
 	switch cmd := command.(type) {
 	case *UnknownCommand:
@@ -142,9 +251,14 @@ func defaultUnknownHandler(ctx context.Context, c *Connection, command Command)
 
 func cleanupChannels(ctx context.Context, c *Connection) error {
 	c.WithWriteLock(ctx, func(conn *Connection) {
-		for channel := range conn.channels {
-			conn.channels[channel] = NewChannel(channel)
+		for _, channel := range conn.channels {
+			channel.Status = NewChannel(channel.Name).Status
+			channel.rejoin.reset()
+			channel.rejoinPending = false
 		}
+
+		conn.pendingNames = map[string]map[string]*Nick{}
+		conn.pendingLists = map[string]map[byte][]string{}
 	})
 
 	return nil
@@ -153,20 +267,79 @@ func cleanupChannels(ctx context.Context, c *Connection) error {
 func defaultPrivmsgHandler(ctx context.Context, c *Connection, command Command) error {
 	switch cmd := command.(type) {
 	case *PrivmsgCommand:
+		// CTCP queries are answered by defaultCTCPResponder, only ACTIONs
+		// are chat
+		if query, ok := cmd.CTCP(); ok && query.Command != CTCPAction {
+			return nil
+		}
+
+		// with echo-message our own messages come back to us
+		if c.isSelf(prefixNick(cmd.Message())) {
+			return nil
+		}
+
 		direct := logger.Param{Key: "directMessage", Value: cmd.IsDirect()}
 		mention := logger.Param{Key: "mentionMessage", Value: cmd.IsMention()}
 		c.log.Debug(cmd.Message().RawMsg, direct, mention)
 
-		e := &tenyksChatMessageEncoder{}
+		e := c.chatMessageEncoder()
 		msg, err := e.Encode(cmd)
 		if err != nil {
 			return err
 		}
 
-		for _, h := range c.chatMessageHandlers {
-			h(msg)
-		}
+		c.dispatchMessage(msg)
 	}
 
 	return nil
 }
+
+// defaultNoticeHandler forwards NOTICEs from users, like NickServ or other
+// bots, to services. Server notices and CTCP replies are only logged.
+func defaultNoticeHandler(ctx context.Context, c *Connection, command Command) error {
+	cmd, ok := command.(*NoticeCommand)
+	if !ok {
+		return nil
+	}
+
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+
+	nick := prefixNick(cmd.Message())
+	if nick == "" {
+		c.log.Info("server notice", logger.Param{Key: "msg", Value: cmd.Message().Trail})
+
+		return nil
+	}
+
+	if c.isSelf(nick) {
+		return nil
+	}
+
+	if reply, ok := cmd.CTCP(); ok {
+		c.log.Debug("CTCP reply",
+			logger.Param{Key: "command", Value: reply.Command},
+			logger.Param{Key: "from", Value: nick},
+			logger.Param{Key: "params", Value: reply.Params})
+
+		return nil
+	}
+
+	e := c.chatMessageEncoder()
+	msg, err := e.EncodeNotice(cmd)
+	if err != nil {
+		return err
+	}
+
+	c.dispatchMessage(msg)
+
+	return nil
+}
+
+// dispatchMessage hands a tenyks message to the registered message handlers.
+func (c *Connection) dispatchMessage(msg message.Message) {
+	for _, h := range c.chatMessageHandlers {
+		h(msg)
+	}
+}
@@ -0,0 +1,333 @@
+package irc
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/kyleterry/tenyks/pkg/logger"
+)
+
+// ChanModes are the channel modes a server supports, grouped by how they take
+// parameters. See the CHANMODES token of RPL_ISUPPORT.
+type ChanModes struct {
+	// List modes add or remove an entry in a list, like bans. They always take
+	// a parameter.
+	List string
+	// Always modes take a parameter when set and unset, like a channel key.
+	Always string
+	// OnSet modes only take a parameter when set, like the user limit.
+	OnSet string
+	// Never modes never take a parameter.
+	Never string
+}
+
+// ServerFeatures is what the server told us about itself in RPL_ISUPPORT
+// (005). Fields are set to what RFC 1459 assumes until the server says
+// otherwise. Length limits other than NickLen and LineLen are 0 when the
+// server didn't advertise them.
+type ServerFeatures struct {
+	Network string
+	// ChanTypes are the characters channel names start with.
+	ChanTypes string
+	// PrefixModes and PrefixChars are the channel membership modes and the
+	// prefixes used for them in NAMES and WHO. They are ordered from most to
+	// least powerful, so PrefixChars[i] is the prefix for PrefixModes[i].
+	PrefixModes string
+	PrefixChars string
+	CaseMapping CaseMapping
+	NickLen     int
+	ChannelLen  int
+	TopicLen    int
+	UserLen     int
+	HostLen     int
+	// LineLen is the longest line the server accepts, including CRLF.
+	LineLen int
+	// Modes is how many modes with parameters can be set in one MODE command.
+	Modes int
+	// TargMax is the maximum number of targets for each command. A command
+	// that's present with a 0 has no limit.
+	TargMax map[string]int
+	// StatusMsg are the prefixes that can go in front of a channel to send a
+	// message to members with that status, like @#channel.
+	StatusMsg string
+	ChanModes ChanModes
+	// Monitor is true if the server supports MONITOR.
+	Monitor bool
+	// Tokens holds every token the server sent, including the ones above,
+	// with escapes decoded.
+	Tokens map[string]string
+}
+
+// NewServerFeatures returns the features to assume before the server sends
+// RPL_ISUPPORT.
+func NewServerFeatures() *ServerFeatures {
+	return &ServerFeatures{
+		ChanTypes:   "#&",
+		PrefixModes: "ov",
+		PrefixChars: "@+",
+		CaseMapping: CaseMappingRFC1459,
+		NickLen:     9,
+		LineLen:     maxLineLength,
+		Modes:       3,
+		TargMax:     map[string]int{},
+		ChanModes: ChanModes{
+			List:   "b",
+			Always: "k",
+			OnSet:  "l",
+			Never:  "imnpst",
+		},
+		Tokens: map[string]string{},
+	}
+}
+
+func (f *ServerFeatures) clone() *ServerFeatures {
+	nf := *f
+	nf.TargMax = map[string]int{}
+	nf.Tokens = map[string]string{}
+
+	for k, v := range f.TargMax {
+		nf.TargMax[k] = v
+	}
+
+	for k, v := range f.Tokens {
+		nf.Tokens[k] = v
+	}
+
+	return &nf
+}
+
+// IsChannel returns true if name is a channel. STATUSMSG prefixes are
+// ignored, so @#channel is a channel.
+func (f *ServerFeatures) IsChannel(name string) bool {
+	name = strings.TrimLeft(name, f.StatusMsg)
+
+	return name != "" && strings.IndexByte(f.ChanTypes, name[0]) != -1
+}
+
+// SplitPrefixes splits the membership prefixes off the front of a name from
+// NAMES or WHO, returning the prefixes and the nick. There may be more than
+// one prefix if multi-prefix is enabled.
+func (f *ServerFeatures) SplitPrefixes(name string) (string, string) {
+	nick := strings.TrimLeft(name, f.PrefixChars)
+
+	return name[:len(name)-len(nick)], nick
+}
+
+// PrefixMode returns the membership mode for a prefix character.
+func (f *ServerFeatures) PrefixMode(prefix byte) (byte, bool) {
+	if i := strings.IndexByte(f.PrefixChars, prefix); i != -1 && i < len(f.PrefixModes) {
+		return f.PrefixModes[i], true
+	}
+
+	return 0, false
+}
+
+// MaxTargets returns the most targets cmd can have at once, or 0 if there's
+// no limit.
+func (f *ServerFeatures) MaxTargets(cmd string) int {
+	return f.TargMax[strings.ToUpper(cmd)]
+}
+
+// apply updates the features with the tokens from an RPL_ISUPPORT reply. A
+// token starting with - means the server no longer supports it and resets it
+// to the default.
+func (f *ServerFeatures) apply(tokens []string) {
+	defaults := NewServerFeatures()
+
+	for _, token := range tokens {
+		if strings.HasPrefix(token, "-") {
+			name := strings.ToUpper(token[1:])
+
+			delete(f.Tokens, name)
+			f.set(name, "", defaults)
+
+			continue
+		}
+
+		name, value := token, ""
+		if i := strings.IndexByte(token, '='); i != -1 {
+			name, value = token[:i], unescapeISupportValue(token[i+1:])
+		}
+
+		name = strings.ToUpper(name)
+
+		f.Tokens[name] = value
+		f.set(name, value, defaults)
+	}
+}
+
+// set updates the field for a token. An empty value for a token with no
+// sensible empty meaning falls back to the default.
+func (f *ServerFeatures) set(name, value string, defaults *ServerFeatures) {
+	_, present := f.Tokens[name]
+
+	switch name {
+	case "NETWORK":
+		f.Network = value
+	case "CHANTYPES":
+		f.ChanTypes = value
+		if !present {
+			f.ChanTypes = defaults.ChanTypes
+		}
+	case "PREFIX":
+		f.PrefixModes, f.PrefixChars = defaults.PrefixModes, defaults.PrefixChars
+
+		// (ov)@+
+		if i := strings.IndexByte(value, ')'); strings.HasPrefix(value, "(") && i != -1 {
+			modes, chars := value[1:i], value[i+1:]
+			if len(modes) == len(chars) {
+				f.PrefixModes, f.PrefixChars = modes, chars
+			}
+		} else if present && value == "" {
+			f.PrefixModes, f.PrefixChars = "", ""
+		}
+	case "CASEMAPPING":
+		f.CaseMapping = defaults.CaseMapping
+		if value != "" {
+			f.CaseMapping = CaseMapping(strings.ToLower(value))
+		}
+	case "NICKLEN":
+		f.NickLen = isupportInt(value, defaults.NickLen)
+	case "CHANNELLEN":
+		f.ChannelLen = isupportInt(value, defaults.ChannelLen)
+	case "TOPICLEN":
+		f.TopicLen = isupportInt(value, defaults.TopicLen)
+	case "USERLEN":
+		f.UserLen = isupportInt(value, defaults.UserLen)
+	case "HOSTLEN":
+		f.HostLen = isupportInt(value, defaults.HostLen)
+	case "LINELEN":
+		f.LineLen = isupportInt(value, defaults.LineLen)
+	case "MODES":
+		f.Modes = isupportInt(value, defaults.Modes)
+	case "TARGMAX":
+		f.TargMax = map[string]int{}
+
+		// PRIVMSG:4,NOTICE:4,JOIN:
+		for _, pair := range strings.Split(value, ",") {
+			i := strings.IndexByte(pair, ':')
+			if i == -1 {
+				continue
+			}
+
+			f.TargMax[strings.ToUpper(pair[:i])] = isupportInt(pair[i+1:], 0)
+		}
+	case "STATUSMSG":
+		f.StatusMsg = value
+	case "CHANMODES":
+		f.ChanModes = defaults.ChanModes
+
+		if present {
+			groups := strings.Split(value, ",")
+			for len(groups) < 4 {
+				groups = append(groups, "")
+			}
+
+			f.ChanModes = ChanModes{
+				List:   groups[0],
+				Always: groups[1],
+				OnSet:  groups[2],
+				Never:  groups[3],
+			}
+		}
+	case "MONITOR":
+		f.Monitor = present
+	}
+}
+
+func isupportInt(value string, def int) int {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return def
+	}
+
+	return n
+}
+
+// unescapeISupportValue decodes the \xHH escapes allowed in RPL_ISUPPORT
+// values.
+func unescapeISupportValue(value string) string {
+	if !strings.Contains(value, `\x`) {
+		return value
+	}
+
+	var sb strings.Builder
+
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+3 < len(value) && value[i+1] == 'x' {
+			if b, err := strconv.ParseUint(value[i+2:i+4], 16, 8); err == nil {
+				sb.WriteByte(byte(b))
+				i += 3
+
+				continue
+			}
+		}
+
+		sb.WriteByte(value[i])
+	}
+
+	return sb.String()
+}
+
+// Features returns what the server has told us about itself. The returned
+// value is replaced, not modified, when the server sends more, so it's safe
+// to hold on to.
+func (c *Connection) Features() *ServerFeatures {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.features
+}
+
+// defaultServerFeaturesHandler updates the connection's ServerFeatures from
+// RPL_ISUPPORT. Servers send several 005 replies during registration, and may
+// send more later when something changes.
+func defaultServerFeaturesHandler(ctx context.Context, c *Connection, reply Reply) error {
+	r, ok := reply.(*ISupportReply)
+	if !ok {
+		return nil
+	}
+
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	var features *ServerFeatures
+
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		previous := conn.features.CaseMapping
+
+		features = conn.features.clone()
+		features.apply(r.Tokens())
+		conn.features = features
+
+		if features.CaseMapping != previous {
+			conn.refold()
+		}
+	})
+
+	c.nickManager.SetMonitor(features.Monitor)
+	c.nickManager.SetCaseMapping(features.CaseMapping)
+
+	c.log.Debug("server features updated",
+		logger.Param{Key: "network", Value: features.Network},
+		logger.Param{Key: "tokens", Value: r.Tokens()})
+
+	return nil
+}
+
+func resetServerFeatures(ctx context.Context, c *Connection) error {
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		previous := conn.features.CaseMapping
+		conn.features = NewServerFeatures()
+
+		if conn.features.CaseMapping != previous {
+			conn.refold()
+		}
+	})
+
+	c.nickManager.SetCaseMapping(CaseMappingRFC1459)
+
+	return nil
+}
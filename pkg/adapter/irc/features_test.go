@@ -0,0 +1,82 @@
+package irc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerFeatures(t *testing.T) {
+	f := NewServerFeatures()
+
+	f.apply([]string{
+		"NETWORK=Example\\x20Net",
+		"CHANTYPES=#",
+		"PREFIX=(qaohv)~&@%+",
+		"CASEMAPPING=ascii",
+		"NICKLEN=30",
+		"CHANNELLEN=64",
+		"TOPICLEN=390",
+		"MODES=4",
+		"TARGMAX=PRIVMSG:4,NOTICE:4,JOIN:",
+		"STATUSMSG=@+",
+		"CHANMODES=beI,k,l,imnpst",
+		"MONITOR=100",
+		"EXCEPTS",
+	})
+
+	require.Equal(t, "Example Net", f.Network)
+	require.Equal(t, "#", f.ChanTypes)
+	require.Equal(t, "qaohv", f.PrefixModes)
+	require.Equal(t, "~&@%+", f.PrefixChars)
+	require.Equal(t, CaseMappingASCII, f.CaseMapping)
+	require.Equal(t, 30, f.NickLen)
+	require.Equal(t, 64, f.ChannelLen)
+	require.Equal(t, 390, f.TopicLen)
+	require.Equal(t, 4, f.Modes)
+	require.Equal(t, 4, f.MaxTargets("privmsg"))
+	require.Equal(t, 0, f.MaxTargets("JOIN"))
+	require.Equal(t, ChanModes{List: "beI", Always: "k", OnSet: "l", Never: "imnpst"}, f.ChanModes)
+	require.True(t, f.Monitor)
+	require.Contains(t, f.Tokens, "EXCEPTS")
+
+	require.True(t, f.IsChannel("#tenyks"))
+	require.True(t, f.IsChannel("@#tenyks"))
+	require.False(t, f.IsChannel("&tenyks"))
+	require.False(t, f.IsChannel("tenyks"))
+
+	prefixes, nick := f.SplitPrefixes("~@tenyks")
+	require.Equal(t, "~@", prefixes)
+	require.Equal(t, "tenyks", nick)
+
+	mode, ok := f.PrefixMode('%')
+	require.True(t, ok)
+	require.Equal(t, byte('h'), mode)
+
+	f.apply([]string{"-MONITOR", "-CHANTYPES", "-PREFIX"})
+
+	require.False(t, f.Monitor)
+	require.Equal(t, "#&", f.ChanTypes)
+	require.Equal(t, "@+", f.PrefixChars)
+	require.NotContains(t, f.Tokens, "MONITOR")
+}
+
+func TestServerFeaturesHandler(t *testing.T) {
+	c := newTestConnection(t, Config{})
+	ctx := context.Background()
+
+	before := c.Features()
+
+	reply := mustDecode(t, c, ":irc.test 005 tenyks CHANTYPES=#+ MONITOR=100 :are supported by this server").(Reply)
+	require.NoError(t, defaultServerFeaturesHandler(ctx, c, reply))
+
+	require.Equal(t, "#+", c.Features().ChanTypes)
+	require.True(t, c.nickManager.supportsMonitor())
+
+	// features are replaced, not modified
+	require.Equal(t, "#&", before.ChanTypes)
+
+	require.NoError(t, resetServerFeatures(ctx, c))
+	require.Equal(t, "#&", c.Features().ChanTypes)
+}
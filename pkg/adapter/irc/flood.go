@@ -0,0 +1,328 @@
+package irc
+
+import (
+	"context"
+	"time"
+
+	"github.com/kyleterry/tenyks/pkg/logger"
+)
+
+const (
+	// DefaultFloodBurst is how many lines can be sent back to back before
+	// flood control starts spacing them out.
+	DefaultFloodBurst = 5
+	// DefaultFloodRate is how long it takes to earn back one line once the
+	// burst is spent.
+	DefaultFloodRate = time.Second * 2
+	// maxFloodTargets is how many per-target buckets we keep before throwing
+	// away the ones that have refilled.
+	maxFloodTargets = 1000
+)
+
+type throttleResult int
+
+const (
+	throttleSend throttleResult = iota
+	throttleDrop
+	throttleStop
+)
+
+// queuedCommand is a Command on the normal send queue along with the time it
+// was queued, so flood control can tell how long it's been waiting.
+type queuedCommand struct {
+	Command
+	queuedAt time.Time
+}
+
+// tokenBucket is a token bucket that refills one token every rate, up to
+// capacity. Tokens are allowed to go negative when a line costs more than
+// what's left, which pushes the next line further out.
+type tokenBucket struct {
+	capacity float64
+	tokens   float64
+	rate     time.Duration
+	last     time.Time
+}
+
+func newTokenBucket(capacity int, rate time.Duration, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		capacity: float64(capacity),
+		tokens:   float64(capacity),
+		rate:     rate,
+		last:     now,
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += float64(elapsed) / float64(b.rate)
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+	}
+
+	b.last = now
+}
+
+// wait returns how long until a line of cost can be sent.
+func (b *tokenBucket) wait(cost float64, now time.Time) time.Duration {
+	b.refill(now)
+
+	// a line can't cost more than a full bucket, otherwise it would never go
+	if cost > b.capacity {
+		cost = b.capacity
+	}
+
+	if b.tokens >= cost {
+		return 0
+	}
+
+	return time.Duration((cost - b.tokens) * float64(b.rate))
+}
+
+func (b *tokenBucket) take(cost float64, now time.Time) {
+	b.refill(now)
+	b.tokens -= cost
+}
+
+func (b *tokenBucket) full(now time.Time) bool {
+	b.refill(now)
+
+	return b.tokens >= b.capacity
+}
+
+// floodControl spaces out outbound lines so the server doesn't disconnect us
+// for flooding. Like most IRC clients it uses a token bucket that allows a
+// short burst and then a steady rate, and optionally charges long lines extra
+// since servers count bytes as well as lines. With per-target limits each
+// PRIVMSG target also gets its own bucket, so one busy channel can't use up
+// the whole allowance.
+//
+// floodControl is only used from the send loop and isn't safe for
+// concurrent use.
+type floodControl struct {
+	burst        int
+	rate         time.Duration
+	targetBurst  int
+	targetRate   time.Duration
+	penaltyBytes int
+	perTarget    bool
+	maxDelay     time.Duration
+
+	global  *tokenBucket
+	targets map[string]*tokenBucket
+}
+
+// newFloodControl returns nil if flood control is disabled by a negative
+// rate.
+func newFloodControl(conf Config) *floodControl {
+	if conf.FloodRate < 0 {
+		return nil
+	}
+
+	fc := &floodControl{
+		burst:        conf.FloodBurst,
+		rate:         conf.FloodRate,
+		targetBurst:  conf.FloodTargetBurst,
+		targetRate:   conf.FloodTargetRate,
+		penaltyBytes: conf.FloodPenaltyBytes,
+		perTarget:    conf.FloodPerTarget,
+		maxDelay:     conf.FloodMaxDelay,
+	}
+
+	if fc.burst <= 0 {
+		fc.burst = DefaultFloodBurst
+	}
+
+	if fc.rate == 0 {
+		fc.rate = DefaultFloodRate
+	}
+
+	if fc.targetBurst <= 0 {
+		fc.targetBurst = fc.burst / 2
+		if fc.targetBurst < 1 {
+			fc.targetBurst = 1
+		}
+	}
+
+	if fc.targetRate <= 0 {
+		fc.targetRate = fc.rate * 2
+	}
+
+	fc.reset()
+
+	return fc
+}
+
+// reset refills every bucket. Servers count flooding per connection, so a new
+// session starts with a full burst.
+func (fc *floodControl) reset() {
+	fc.global = newTokenBucket(fc.burst, fc.rate, time.Now())
+	fc.targets = map[string]*tokenBucket{}
+}
+
+// cost returns how many tokens a line costs. Every line costs one, plus one
+// for every penaltyBytes bytes if byte penalties are enabled.
+func (fc *floodControl) cost(line string) float64 {
+	cost := 1.0
+
+	if fc.penaltyBytes > 0 {
+		cost += float64(len(line)) / float64(fc.penaltyBytes)
+	}
+
+	return cost
+}
+
+func (fc *floodControl) targetBucket(target string, now time.Time) *tokenBucket {
+	if !fc.perTarget || target == "" {
+		return nil
+	}
+
+	b, ok := fc.targets[target]
+	if !ok {
+		if len(fc.targets) >= maxFloodTargets {
+			fc.prune(now)
+		}
+
+		b = newTokenBucket(fc.targetBurst, fc.targetRate, now)
+		fc.targets[target] = b
+	}
+
+	return b
+}
+
+func (fc *floodControl) prune(now time.Time) {
+	for target, b := range fc.targets {
+		if b.full(now) {
+			delete(fc.targets, target)
+		}
+	}
+}
+
+// delay returns how long a line to target has to wait before it can be sent.
+// target is empty for lines without one.
+func (fc *floodControl) delay(target, line string, now time.Time) time.Duration {
+	cost := fc.cost(line)
+	delay := fc.global.wait(cost, now)
+
+	if b := fc.targetBucket(target, now); b != nil {
+		if d := b.wait(cost, now); d > delay {
+			delay = d
+		}
+	}
+
+	return delay
+}
+
+// take charges the buckets for sending a line to target.
+func (fc *floodControl) take(target, line string, now time.Time) {
+	cost := fc.cost(line)
+
+	fc.global.take(cost, now)
+
+	if b := fc.targetBucket(target, now); b != nil {
+		b.take(cost, now)
+	}
+}
+
+// expired returns true if cmd has been queued longer than the configured max
+// delay and should be dropped instead of sent.
+func (fc *floodControl) expired(cmd Command, now time.Time) bool {
+	if fc.maxDelay <= 0 {
+		return false
+	}
+
+	q, ok := cmd.(*queuedCommand)
+	if !ok {
+		return false
+	}
+
+	return now.Sub(q.queuedAt) > fc.maxDelay
+}
+
+// floodTarget returns the target of a message for per-target limits, folded
+// so differently cased names share a bucket, or an empty string if the
+// command doesn't have one.
+func (c *Connection) floodTarget(cmd Command) string {
+	msg := cmd.Message()
+
+	switch msg.Command {
+	case "PRIVMSG", "NOTICE", "TAGMSG":
+		if len(msg.Params) > 0 {
+			return c.Features().CaseMapping.Fold(msg.Params[0])
+		}
+	}
+
+	return ""
+}
+
+// throttle waits until flood control allows cmd to be sent. Priority commands
+// are written with write while it waits so PONGs aren't held up behind a
+// backlog of messages. It returns throttleDrop if cmd waited longer than the
+// max delay and throttleStop if the session ended, in which case cmd is kept
+// for the next session.
+func (c *Connection) throttle(ctx context.Context, cmd Command, write func(Command) bool) throttleResult {
+	line, err := cmd.Encode()
+	if err != nil {
+		// let write report it
+		return throttleSend
+	}
+
+	var delayed bool
+
+	target := c.floodTarget(cmd)
+
+	for {
+		now := time.Now()
+
+		if c.flood.expired(cmd, now) {
+			c.WithWriteLock(ctx, func(conn *Connection) {
+				conn.Status.FloodDropped++
+			})
+
+			c.log.Error("flood control dropped message",
+				logger.Param{Key: "connection", Value: c.Name},
+				logger.Param{Key: "target", Value: target},
+				logger.Param{Key: "waited", Value: now.Sub(cmd.(*queuedCommand).queuedAt)})
+
+			return throttleDrop
+		}
+
+		delay := c.flood.delay(target, line, now)
+		if delay <= 0 {
+			return throttleSend
+		}
+
+		if !delayed {
+			delayed = true
+
+			c.WithWriteLock(ctx, func(conn *Connection) {
+				conn.Status.FloodDelayed++
+			})
+
+			c.log.Debug("flood control delayed message",
+				logger.Param{Key: "connection", Value: c.Name},
+				logger.Param{Key: "target", Value: target},
+				logger.Param{Key: "delay", Value: delay})
+		}
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case p := <-c.priority:
+			timer.Stop()
+
+			if !write(p) {
+				c.requeue(cmd)
+
+				return throttleStop
+			}
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			c.requeue(cmd)
+
+			return throttleStop
+		}
+	}
+}
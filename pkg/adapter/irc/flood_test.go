@@ -0,0 +1,145 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(2, time.Second, now)
+
+	require.Zero(t, b.wait(1, now))
+	b.take(1, now)
+	require.Zero(t, b.wait(1, now))
+	b.take(1, now)
+
+	require.Equal(t, time.Second, b.wait(1, now))
+	require.Equal(t, time.Millisecond*500, b.wait(1, now.Add(time.Millisecond*500)))
+	require.Zero(t, b.wait(1, now.Add(time.Second)))
+
+	// a line costing more than the bucket holds waits for a full bucket
+	require.Equal(t, time.Second, b.wait(5, now.Add(time.Second)))
+	require.True(t, b.full(now.Add(time.Second*10)))
+}
+
+func TestFloodControl(t *testing.T) {
+	t.Run("per target", func(t *testing.T) {
+		fc := newFloodControl(Config{FloodBurst: 4, FloodRate: time.Second, FloodPerTarget: true})
+		now := time.Now()
+
+		fc.take("#a", "", now)
+		fc.take("#a", "", now)
+
+		// #a spent its burst of 2 and earns a line back every 2 seconds,
+		// while the connection still has 2 lines left.
+		require.Equal(t, time.Second*2, fc.delay("#a", "", now))
+		require.Zero(t, fc.delay("#b", "", now))
+		require.Zero(t, fc.delay("", "", now))
+
+		fc = newFloodControl(Config{FloodPerTarget: true, FloodTargetBurst: 1, FloodTargetRate: time.Second * 10})
+		fc.take("#a", "", now)
+
+		require.Equal(t, time.Second*10, fc.delay("#a", "", now))
+		require.Zero(t, fc.delay("#b", "", now))
+	})
+
+	t.Run("penalty", func(t *testing.T) {
+		fc := newFloodControl(Config{FloodPenaltyBytes: 100})
+
+		require.Equal(t, 1.0, fc.cost(""))
+		require.Equal(t, 2.5, fc.cost(string(make([]byte, 150))))
+	})
+
+	t.Run("max delay", func(t *testing.T) {
+		fc := newFloodControl(Config{FloodMaxDelay: time.Second})
+		now := time.Now()
+
+		fresh := &queuedCommand{Command: NewPrivmsgCommand("#a", "hi"), queuedAt: now}
+		stale := &queuedCommand{Command: NewPrivmsgCommand("#a", "hi"), queuedAt: now.Add(-time.Minute)}
+
+		require.False(t, fc.expired(fresh, now))
+		require.True(t, fc.expired(stale, now))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		require.Nil(t, newFloodControl(Config{FloodRate: -1}))
+	})
+}
+
+func TestThrottle(t *testing.T) {
+	c := newTestConnection(t, Config{FloodBurst: 1, FloodRate: time.Millisecond * 200})
+
+	cmd := &queuedCommand{Command: NewPrivmsgCommand("#tenyks", "hello"), queuedAt: time.Now()}
+	c.flood.take(c.floodTarget(cmd), "", time.Now())
+
+	require.NoError(t, c.EnqueueCommand(NewPongCommand("irc.example.com")))
+
+	var written []Command
+
+	write := func(cmd Command) bool {
+		written = append(written, cmd)
+
+		return true
+	}
+
+	require.Equal(t, throttleSend, c.throttle(context.Background(), cmd, write))
+	require.Len(t, written, 1)
+	require.IsType(t, &PongCommand{}, written[0])
+	require.EqualValues(t, 1, c.Status.FloodDelayed)
+
+	t.Run("dropped", func(t *testing.T) {
+		c := newTestConnection(t, Config{FloodBurst: 1, FloodMaxDelay: time.Millisecond})
+
+		cmd := &queuedCommand{Command: NewPrivmsgCommand("#tenyks", "hello"), queuedAt: time.Now().Add(-time.Second)}
+
+		require.Equal(t, throttleDrop, c.throttle(context.Background(), cmd, write))
+		require.EqualValues(t, 1, c.Status.FloodDropped)
+	})
+}
+
+func TestRestoreCommandsAreThrottled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := newTestConnection(t, Config{FloodBurst: 1, FloodRate: time.Millisecond * 100})
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	s := newSession(ctx, client)
+	c.session = s
+
+	require.NoError(t, c.EnqueueCommand(NewPrivmsgCommand("#tenyks", "queued while away")))
+
+	start := time.Now()
+	c.startSendLoop(ctx, s)
+
+	require.NoError(t, c.enqueueRestoreCommand(NewJoinCommand("#tenyks")))
+	require.NoError(t, c.enqueueRestoreCommand(NewJoinCommand("#ops")))
+	s.markRegistered()
+
+	r := bufio.NewReader(server)
+	lines := []string{}
+
+	for i := 0; i < 3; i++ {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+
+		lines = append(lines, line)
+	}
+
+	// JOINs go ahead of the backlog, but each one pays for its line
+	require.Equal(t, []string{
+		"JOIN #tenyks\r\n",
+		"JOIN #ops\r\n",
+		"PRIVMSG #tenyks :queued while away\r\n",
+	}, lines)
+	require.True(t, time.Since(start) >= time.Millisecond*200)
+}
@@ -0,0 +1,244 @@
+package irc
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kyleterry/tenyks/pkg/logger"
+	"github.com/kyleterry/tenyks/pkg/message"
+)
+
+// accountMaskPrefix marks an InviteAllowlist entry as a services account
+// instead of a hostmask, the same way extended bans do.
+const accountMaskPrefix = "$a:"
+
+// matchMask returns true if s matches mask, where * matches any number of
+// characters and ? matches exactly one.
+func matchMask(mask, s string) bool {
+	for len(mask) > 0 {
+		switch mask[0] {
+		case '*':
+			mask = strings.TrimLeft(mask, "*")
+			if mask == "" {
+				return true
+			}
+
+			for i := 0; i <= len(s); i++ {
+				if matchMask(mask, s[i:]) {
+					return true
+				}
+			}
+
+			return false
+		case '?':
+			if s == "" {
+				return false
+			}
+		default:
+			if s == "" || s[0] != mask[0] {
+				return false
+			}
+		}
+
+		mask, s = mask[1:], s[1:]
+	}
+
+	return s == ""
+}
+
+// inviteWhoisTimeout is how long we wait for the server to tell us the
+// account of someone who invited us before turning the invite down.
+const inviteWhoisTimeout = time.Second * 30
+
+// hostmaskAllowed returns true if the user that sent msg matches a hostmask
+// on the invite allowlist.
+func (c *Connection) hostmaskAllowed(msg *Message) bool {
+	if msg.PrefixSection == nil || msg.PrefixSection.Nick == "" {
+		return false
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+
+	for _, entry := range c.inviteAllowlist {
+		if strings.HasPrefix(entry, accountMaskPrefix) {
+			continue
+		}
+
+		if matchMask(c.fold(entry), c.fold(msg.PrefixSection.RawPrefix)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// accountAllowed returns true if account is on the invite allowlist.
+func (c *Connection) accountAllowed(account string) bool {
+	if account == "" || account == "*" {
+		return false
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+
+	for _, entry := range c.inviteAllowlist {
+		if strings.HasPrefix(entry, accountMaskPrefix) && c.features.CaseMapping.Equal(account, entry[len(accountMaskPrefix):]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowsAccounts returns true if the invite allowlist has account entries.
+func (c *Connection) allowsAccounts() bool {
+	for _, entry := range c.inviteAllowlist {
+		if strings.HasPrefix(entry, accountMaskPrefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// inviterAccount returns the account of the user that sent msg and whether
+// it can be trusted. The account tag always can. What we know about the
+// sender from our channels is only kept up to date with account-notify, so
+// without it we have to ask the server.
+func (c *Connection) inviterAccount(msg *Message) (string, bool) {
+	if account, ok := msg.Tag("account"); ok {
+		return account, true
+	}
+
+	if c.HasCapability("account-notify") {
+		return c.senderAccount(msg), true
+	}
+
+	return "", false
+}
+
+// newChannel returns a channel that uses the connection's default rejoin
+// policy.
+func (c *Connection) newChannel(name string) *Channel {
+	channel := NewChannel(name)
+	channel.RejoinPolicy = c.rejoinPolicy
+	channel.rejoin = c.rejoinBackoff
+
+	return channel
+}
+
+// defaultInviteHandler joins channels we're invited to by someone on the
+// invite allowlist. The channel is added to our channels, so we rejoin it
+// after a reconnect. Services get an invite event either way. If the
+// inviter's account can't be trusted, it's looked up with WHOIS first.
+func defaultInviteHandler(ctx context.Context, c *Connection, command Command) error {
+	cmd, ok := command.(*InviteCommand)
+	if !ok {
+		return nil
+	}
+
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+
+	// with invite-notify we also hear about others being invited
+	if !c.isSelf(cmd.Nick()) {
+		return nil
+	}
+
+	msg := cmd.Message()
+
+	if c.hostmaskAllowed(msg) {
+		return c.answerInvite(ctx, cmd, true)
+	}
+
+	if account, ok := c.inviterAccount(msg); ok || !c.allowsAccounts() {
+		return c.answerInvite(ctx, cmd, c.accountAllowed(account))
+	}
+
+	c.verifyInvite(cmd)
+
+	return nil
+}
+
+// verifyInvite asks the server for the account of whoever sent cmd and
+// answers the invite once it knows. SendAndWait can't be called from the
+// dispatcher, so this happens on its own goroutine.
+func (c *Connection) verifyInvite(cmd *InviteCommand) {
+	sctx := c.sessionContext()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(sctx, inviteWhoisTimeout)
+		defer cancel()
+
+		var accepted bool
+
+		user, err := c.Whois(ctx, prefixNick(cmd.Message()))
+		if err != nil {
+			c.log.Error("failed to look up inviter",
+				logger.Param{Key: "nick", Value: prefixNick(cmd.Message())},
+				logger.Param{Key: "error", Value: err})
+		} else {
+			accepted = c.accountAllowed(user.Account)
+		}
+
+		if err := c.answerInvite(sctx, cmd, accepted); err != nil {
+			c.log.Error("failed to answer invite", logger.Param{Key: "error", Value: err})
+		}
+	}()
+}
+
+// answerInvite tells services about the invite in cmd and joins the channel
+// if it was accepted.
+func (c *Connection) answerInvite(ctx context.Context, cmd *InviteCommand, accepted bool) error {
+	msg := cmd.Message()
+	name := cmd.Channel()
+	inviter := prefixNick(msg)
+
+	c.log.Info("invited to channel",
+		logger.Param{Key: "channel", Value: name},
+		logger.Param{Key: "by", Value: inviter},
+		logger.Param{Key: "accepted", Value: accepted})
+
+	event := &message.EventMessage{
+		Kind:       message.EventKindInvite,
+		TargetPath: c.targetPath(name),
+		Attributes: map[string]string{"accepted": strconv.FormatBool(accepted)},
+		Timestamp:  time.Now(),
+	}
+
+	if inviter != "" {
+		event.OriginPath = c.targetPath(inviter)
+		event.Attributes["invitedBy"] = msg.PrefixSection.RawPrefix
+	}
+
+	c.dispatchMessage(event)
+
+	if !accepted || !c.Features().IsChannel(name) {
+		return nil
+	}
+
+	var join *Channel
+
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		channel, ok := conn.channel(name)
+		if !ok {
+			channel = conn.newChannel(name)
+			conn.channels[conn.fold(name)] = channel
+		}
+
+		if channel.Status.Status != ChannelStatusJoined {
+			channel.rejoinPending = false
+			join = channel
+		}
+	})
+
+	if join == nil {
+		return nil
+	}
+
+	return c.enqueueRestoreCommand(c.joinCommands([]*Channel{join})[0])
+}
@@ -0,0 +1,117 @@
+package irc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kyleterry/tenyks/pkg/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchMask(t *testing.T) {
+	require.True(t, matchMask("*!*@trusted.example.com", "alice!~alice@trusted.example.com"))
+	require.True(t, matchMask("alice!*@*", "alice!~alice@user/alice"))
+	require.True(t, matchMask("?ob!*", "bob!bob@example.com"))
+	require.False(t, matchMask("*!*@trusted.example.com", "mallory!~m@untrusted.example.com"))
+	require.False(t, matchMask("?ob!*", "blob!bob@example.com"))
+}
+
+func TestInvite(t *testing.T) {
+	c := newTestConnection(t, Config{
+		Name:            "test",
+		Channels:        []string{"#tenyks"},
+		InviteAllowlist: []string{"*!*@trusted.example.com", "$a:carol"},
+		RejoinPolicy:    RejoinPolicyNever,
+	})
+	c.Status.CurrentNick = "tenyks"
+	// we know the account of everyone we can see, so nobody is looked up
+	c.Status.Capabilities["account-notify"] = ""
+
+	events := []*message.EventMessage{}
+	c.RegisterMessageHandler(func(msg message.Message) {
+		events = append(events, msg.(*message.EventMessage))
+	})
+
+	require.IsType(t, &InviteCommand{}, mustDecode(t, c, ":alice!~alice@trusted.example.com INVITE tenyks :#new"))
+
+	dispatch(t, c, ":mallory!~m@untrusted.example.com INVITE tenyks :#evil")
+	dispatch(t, c, ":alice!~alice@Trusted.Example.com INVITE tenyks :#new")
+	dispatch(t, c, "@account=carol :carol!~carol@elsewhere.example.com INVITE tenyks #Other")
+	dispatch(t, c, ":alice!~alice@trusted.example.com INVITE bob #new")
+
+	require.Equal(t, []string{"JOIN #new\r\n", "JOIN #Other\r\n"}, drainCommands(t, c))
+
+	c.RLock()
+	require.Len(t, c.channels, 3)
+	require.Equal(t, RejoinPolicyNever, c.channels["#other"].RejoinPolicy)
+	c.RUnlock()
+
+	require.Len(t, events, 3)
+	require.Equal(t, message.EventKindInvite, events[0].Kind)
+	require.Equal(t, "/irc/test/#evil", events[0].TargetPath)
+	require.Equal(t, "false", events[0].Attributes["accepted"])
+	require.Equal(t, "/irc/test/alice", events[1].OriginPath)
+	require.Equal(t, "true", events[1].Attributes["accepted"])
+	require.Equal(t, "true", events[2].Attributes["accepted"])
+
+	// we're already in there, so there's nothing to join
+	dispatch(t, c, ":tenyks!~tenyks@example.com JOIN #new")
+	drainCommands(t, c)
+
+	dispatch(t, c, ":alice!~alice@trusted.example.com INVITE tenyks :#new")
+	require.Empty(t, drainCommands(t, c))
+
+	t.Run("account without account-tag", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		c.session = newSession(ctx, nil)
+		defer func() { c.session = nil }()
+
+		dispatch(t, c, ":tenyks!~tenyks@example.com JOIN #new")
+		dispatch(t, c, ":carol!~carol@elsewhere.example.com JOIN #new carol :Carol")
+		drainCommands(t, c)
+		events = nil
+		delete(c.Status.Capabilities, "account-notify")
+
+		answered := make(chan struct{}, 2)
+		c.RegisterMessageHandler(func(message.Message) {
+			answered <- struct{}{}
+		})
+
+		// without account-notify carol may have logged out since she joined,
+		// so we ask
+		dispatch(t, c, ":carol!~carol@elsewhere.example.com INVITE tenyks #ops")
+		require.Equal(t, []string{"WHOIS carol\r\n"}, waitForCommands(t, c, 1))
+
+		dispatch(t, c, ":irc.test 311 tenyks carol ~carol elsewhere.example.com * :Carol")
+		dispatch(t, c, ":irc.test 318 tenyks carol :End of /WHOIS list.")
+
+		select {
+		case <-answered:
+		case <-time.After(time.Second):
+			t.Fatal("invite was never answered")
+		}
+
+		require.Empty(t, drainCommands(t, c))
+
+		dispatch(t, c, ":carol!~carol@elsewhere.example.com INVITE tenyks #ops")
+		require.Equal(t, []string{"WHOIS carol\r\n"}, waitForCommands(t, c, 1))
+
+		dispatch(t, c, ":irc.test 311 tenyks carol ~carol elsewhere.example.com * :Carol")
+		dispatch(t, c, ":irc.test 330 tenyks carol carol :is logged in as")
+		dispatch(t, c, ":irc.test 318 tenyks carol :End of /WHOIS list.")
+
+		require.Equal(t, []string{"JOIN #ops\r\n"}, waitForCommands(t, c, 1))
+		require.Len(t, events, 2)
+		require.Equal(t, "false", events[0].Attributes["accepted"])
+		require.Equal(t, "true", events[1].Attributes["accepted"])
+
+		// with account-notify what we know is kept up to date
+		c.Status.Capabilities["account-notify"] = ""
+
+		dispatch(t, c, ":carol!~carol@elsewhere.example.com INVITE tenyks #ops2")
+		require.Equal(t, []string{"JOIN #ops2\r\n"}, drainCommands(t, c))
+	})
+}
@@ -0,0 +1,77 @@
+package irc
+
+import (
+	"sort"
+	"strings"
+)
+
+// joinCommands returns the JOIN commands for channels, split up so each one
+// stays within the server's line length and JOIN target limit.
+func (c *Connection) joinCommands(channels []*Channel) []*JoinCommand {
+	features := c.Features()
+
+	c.RLock()
+	defer c.RUnlock()
+
+	return joinBatches(channels, features.MaxTargets("JOIN"), features.LineLen)
+}
+
+// joinBatches groups channels into JOIN commands of at most maxTargets
+// channels that fit in lineLen bytes. Channels with a key are put first since
+// JOIN pairs keys with channels by position. A maxTargets of 0 means there's
+// no limit on the number of channels.
+func joinBatches(channels []*Channel, maxTargets, lineLen int) []*JoinCommand {
+	sorted := append([]*Channel{}, channels...)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if (sorted[i].Key != "") != (sorted[j].Key != "") {
+			return sorted[i].Key != ""
+		}
+
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	cmds := []*JoinCommand{}
+	names, keys := []string{}, []string{}
+
+	flush := func() {
+		if len(names) > 0 {
+			cmds = append(cmds, NewKeyedJoinCommand(names, keys))
+		}
+
+		names, keys = []string{}, []string{}
+	}
+
+	for _, channel := range sorted {
+		nextNames, nextKeys := append(names, channel.Name), keys
+		if channel.Key != "" {
+			nextKeys = append(keys, channel.Key)
+		}
+
+		full := maxTargets > 0 && len(names) >= maxTargets
+		if len(names) > 0 && (full || joinLength(nextNames, nextKeys) > lineLen) {
+			flush()
+		}
+
+		names = append(names, channel.Name)
+		if channel.Key != "" {
+			keys = append(keys, channel.Key)
+		}
+	}
+
+	flush()
+
+	return cmds
+}
+
+// joinLength is the length of the JOIN line for names and keys, including
+// the CR-LF.
+func joinLength(names, keys []string) int {
+	n := len("JOIN ") + len(strings.Join(names, ",")) + len("\r\n")
+
+	if len(keys) > 0 {
+		n += len(" ") + len(strings.Join(keys, ","))
+	}
+
+	return n
+}
@@ -0,0 +1,102 @@
+package irc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func encodeJoins(t *testing.T, cmds []*JoinCommand) []string {
+	t.Helper()
+
+	lines := []string{}
+
+	for _, cmd := range cmds {
+		line, err := cmd.Encode()
+		require.NoError(t, err)
+
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+func TestJoinBatches(t *testing.T) {
+	channels := []*Channel{
+		{Name: "#public"},
+		{Name: "#ops", Key: "hunter2"},
+		{Name: "#chat"},
+		{Name: "#admin", Key: "sekrit"},
+	}
+
+	require.Equal(t, []string{
+		"JOIN #admin,#ops,#chat,#public sekrit,hunter2\r\n",
+	}, encodeJoins(t, joinBatches(channels, 0, maxLineLength)))
+
+	require.Equal(t, []string{
+		"JOIN #admin,#ops sekrit,hunter2\r\n",
+		"JOIN #chat,#public\r\n",
+	}, encodeJoins(t, joinBatches(channels, 2, maxLineLength)))
+
+	require.Equal(t, []string{
+		"JOIN #admin,#ops sekrit,hunter2\r\n",
+		"JOIN #chat,#public\r\n",
+	}, encodeJoins(t, joinBatches(channels, 0, len("JOIN #admin,#ops sekrit,hunter2\r\n"))))
+
+	t.Run("hundreds of channels", func(t *testing.T) {
+		channels := []*Channel{}
+		for i := 0; i < 300; i++ {
+			channels = append(channels, &Channel{Name: fmt.Sprintf("#channel-%03d", i)})
+		}
+
+		cmds := joinBatches(channels, 0, maxLineLength)
+		require.True(t, len(cmds) > 1)
+
+		for _, line := range encodeJoins(t, cmds) {
+			require.True(t, len(line) <= maxLineLength)
+		}
+
+		var joined int
+
+		for _, cmd := range cmds {
+			joined += len(strings.Split(cmd.Message().Params[0], ","))
+		}
+
+		require.Equal(t, 300, joined)
+	})
+}
+
+func TestJoinAfterISupport(t *testing.T) {
+	c := newTestConnection(t, Config{Channels: []string{"#tenyks", "#ops", "#dev"}})
+
+	// nothing is joined until the server has told us its limits
+	dispatch(t, c, ":irc.test 001 tenyks :Welcome")
+	require.Empty(t, drainCommands(t, c))
+
+	dispatch(t, c, ":irc.test 005 tenyks TARGMAX=JOIN:2 :are supported by this server")
+	dispatch(t, c, ":irc.test 376 tenyks :End of /MOTD command.")
+
+	require.Equal(t, []string{
+		"JOIN #dev,#ops\r\n",
+		"JOIN #tenyks\r\n",
+	}, drainCommands(t, c))
+}
+
+func TestJoinWithKeys(t *testing.T) {
+	c := newTestConnection(t, Config{
+		Channels:    []string{"#tenyks", "#ops", "#dev"},
+		ChannelKeys: map[string]string{"#OPS": "hunter2"},
+	})
+
+	dispatch(t, c, ":irc.test 005 tenyks TARGMAX=JOIN:2,PRIVMSG:4 :are supported by this server")
+
+	require.NoError(t, defaultJoinFunc(context.Background(), c))
+
+	require.Equal(t, []string{
+		"JOIN #ops,#dev hunter2\r\n",
+		"JOIN #tenyks\r\n",
+	}, drainCommands(t, c))
+}
@@ -0,0 +1,127 @@
+package irc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kyleterry/tenyks/pkg/logger"
+)
+
+const (
+	// DefaultPingInterval is how long we wait between our own PINGs.
+	DefaultPingInterval = time.Minute
+	// DefaultPingTimeout is how long we wait for the server to answer a PING
+	// before declaring the link dead.
+	DefaultPingTimeout = time.Minute * 2
+)
+
+// probe is a PING we sent that the server hasn't answered yet.
+type probe struct {
+	token    string
+	sent     time.Time
+	answered chan struct{}
+}
+
+// sendProbe sends a PING with a unique token and records it so the matching
+// PONG can be found.
+func (c *Connection) sendProbe(ctx context.Context) (*probe, error) {
+	now := time.Now()
+	p := &probe{
+		token:    fmt.Sprintf("tenyks-%d", now.UnixNano()),
+		sent:     now,
+		answered: make(chan struct{}),
+	}
+
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		conn.probe = p
+		conn.Status.LastServerProbe = now
+	})
+
+	if err := c.EnqueueCommand(NewPingCommand(p.token)); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// defaultKeepaliveFunc starts pinging the server once we're registered. If
+// a PING isn't answered within the timeout, the session is ended so the
+// supervisor reconnects.
+func defaultKeepaliveFunc(ctx context.Context, c *Connection) error {
+	sctx := c.sessionContext()
+
+	go func() {
+		interval := time.NewTimer(c.pingInterval)
+		defer interval.Stop()
+
+		for {
+			select {
+			case <-sctx.Done():
+				return
+			case <-interval.C:
+			}
+
+			p, err := c.sendProbe(sctx)
+			if err != nil {
+				c.log.Error("failed to ping server", logger.Param{Key: "error", Value: err})
+				interval.Reset(c.pingInterval)
+
+				continue
+			}
+
+			timeout := time.NewTimer(c.pingTimeout)
+
+			select {
+			case <-sctx.Done():
+				timeout.Stop()
+
+				return
+			case <-p.answered:
+				timeout.Stop()
+			case <-timeout.C:
+				c.disconnect(fmt.Errorf("%w: no response after %s", ErrPingTimeout, c.pingTimeout))
+
+				return
+			}
+
+			interval.Reset(c.pingInterval)
+		}
+	}()
+
+	return nil
+}
+
+// defaultPongHandler matches PONGs to our outstanding PING and records the
+// round trip as the connection's lag.
+func defaultPongHandler(ctx context.Context, c *Connection, command Command) error {
+	cmd, ok := command.(*PongCommand)
+	if !ok {
+		return nil
+	}
+
+	now := time.Now()
+
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		p := conn.probe
+		if p == nil || p.token != cmd.Token() {
+			return
+		}
+
+		conn.probe = nil
+		conn.Status.LastServerProbeResponse = now
+		conn.Status.Lag = now.Sub(p.sent)
+
+		close(p.answered)
+	})
+
+	return nil
+}
+
+func resetKeepalive(ctx context.Context, c *Connection) error {
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		conn.probe = nil
+	})
+
+	return nil
+}
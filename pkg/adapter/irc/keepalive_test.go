@@ -0,0 +1,73 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeepalive(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	c := newTestConnection(t, Config{
+		Server:       ln.Addr().String(),
+		User:         "tenyks",
+		RealName:     "tenyks",
+		PingInterval: time.Millisecond * 20,
+		PingTimeout:  time.Millisecond * 100,
+	})
+	c.backoff = backoff{min: time.Millisecond, max: time.Millisecond * 10}
+
+	accepted := make(chan *fakeServer)
+	go func() {
+		for i := 0; i < 2; i++ {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			accepted <- &fakeServer{t: t, conn: conn, r: bufio.NewReader(conn)}
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	require.NoError(t, c.Dial(ctx))
+	defer c.Close(ctx)
+
+	first := <-accepted
+	defer first.conn.Close()
+
+	first.readUntil("NICK")
+	first.send(":irc.test 001 tenyks :Welcome")
+	first.send(":irc.test 422 tenyks :MOTD File is missing")
+
+	lines := first.readUntil("PING")
+	token := strings.TrimPrefix(lines[len(lines)-1], "PING :")
+	first.send(":irc.test PONG irc.test :" + token)
+
+	// the next PING goes unanswered, so we should get a new connection
+	first.readUntil("PING")
+
+	select {
+	case second := <-accepted:
+		second.conn.Close()
+	case <-time.After(time.Second * 5):
+		t.Fatal("no reconnect after ping timeout")
+	}
+
+	var lag time.Duration
+	c.WithReadLock(ctx, func(conn *Connection) {
+		lag = conn.Status.Lag
+	})
+
+	require.NotZero(t, lag)
+}
@@ -0,0 +1,151 @@
+package irc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kyleterry/tenyks/pkg/logger"
+)
+
+// channel returns the channel called name. The caller must hold the
+// connection's lock.
+func (c *Connection) channel(name string) (*Channel, bool) {
+	channel, ok := c.channels[c.fold(name)]
+
+	return channel, ok
+}
+
+// prefixNick returns the nick of the user that sent msg, or an empty string
+// if it came from the server.
+func prefixNick(msg *Message) string {
+	if msg.PrefixSection == nil {
+		return ""
+	}
+
+	return msg.PrefixSection.Nick
+}
+
+// forEachMember calls fn with the member entry for nick in every channel
+// they're in. The caller must hold the connection's lock.
+func (c *Connection) forEachMember(nick string, fn func(*Nick)) {
+	key := c.fold(nick)
+
+	for _, channel := range c.channels {
+		if member, ok := channel.Status.Nicks[key]; ok {
+			fn(member)
+		}
+	}
+}
+
+// defaultMembershipTracker keeps the member lists of our channels up to date
+// as others join, leave, get kicked, quit and change nicks. Our own JOINs are
+// handled by defaultJoinChannelStatusUpdater.
+func defaultMembershipTracker(ctx context.Context, c *Connection, command Command) error {
+	switch cmd := command.(type) {
+	case *JoinCommand:
+		nick := prefixNick(cmd.Message())
+		if nick == "" || c.isSelf(nick) {
+			return nil
+		}
+
+		prefix := cmd.Message().PrefixSection
+
+		c.WithWriteLock(ctx, func(conn *Connection) {
+			if channel, ok := conn.channel(cmd.Channel()); ok {
+				channel.Status.Nicks[conn.fold(nick)] = &Nick{
+					Name:     nick,
+					Ident:    prefix.Ident,
+					Host:     prefix.Host,
+					Account:  cmd.Account(),
+					RealName: cmd.RealName(),
+				}
+			}
+		})
+	case *PartCommand:
+		nick := prefixNick(cmd.Message())
+		if nick == "" {
+			return nil
+		}
+
+		if c.isSelf(nick) {
+			c.leaveChannel(ctx, cmd.Channel(), cmd.Reason())
+
+			return nil
+		}
+
+		c.removeMember(ctx, cmd.Channel(), nick)
+	case *KickCommand:
+		if err := cmd.Validate(); err != nil {
+			return err
+		}
+
+		if c.isSelf(cmd.Nick()) {
+			by := prefixNick(cmd.Message())
+
+			c.log.Info("kicked from channel",
+				logger.Param{Key: "channel", Value: cmd.Channel()},
+				logger.Param{Key: "by", Value: by},
+				logger.Param{Key: "reason", Value: cmd.Reason()})
+
+			c.channelFailed(ctx, cmd.Channel(), fmt.Sprintf("kicked by %s: %s", by, cmd.Reason()))
+
+			return nil
+		}
+
+		c.removeMember(ctx, cmd.Channel(), cmd.Nick())
+	case *QuitCommand:
+		nick := prefixNick(cmd.Message())
+		if nick == "" {
+			return nil
+		}
+
+		c.WithWriteLock(ctx, func(conn *Connection) {
+			key := conn.fold(nick)
+
+			for _, channel := range conn.channels {
+				delete(channel.Status.Nicks, key)
+			}
+		})
+	case *NickCommand:
+		from := prefixNick(cmd.Message())
+		to := cmd.Nick()
+
+		if from == "" || to == "" {
+			return nil
+		}
+
+		c.WithWriteLock(ctx, func(conn *Connection) {
+			fromKey, toKey := conn.fold(from), conn.fold(to)
+
+			for _, channel := range conn.channels {
+				if nick, ok := channel.Status.Nicks[fromKey]; ok {
+					delete(channel.Status.Nicks, fromKey)
+
+					nick.Name = to
+					channel.Status.Nicks[toKey] = nick
+				}
+			}
+		})
+	}
+
+	return nil
+}
+
+// leaveChannel marks a channel as parted after we leave it.
+func (c *Connection) leaveChannel(ctx context.Context, name, reason string) {
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		if channel, ok := conn.channel(name); ok {
+			channel.Status.Status = ChannelStatusParted
+			channel.Status.Message = reason
+			channel.Status.reset()
+		}
+	})
+}
+
+func (c *Connection) removeMember(ctx context.Context, name, nick string) {
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		if channel, ok := conn.channel(name); ok {
+			delete(channel.Status.Nicks, conn.fold(nick))
+		}
+	})
+}
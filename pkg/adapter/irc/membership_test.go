@@ -0,0 +1,98 @@
+package irc
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// dispatch runs a raw line through the connection's hooks the same way the
+// dispatcher does.
+func dispatch(t *testing.T, c *Connection, raw string) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	switch mo := mustDecode(t, c, raw).(type) {
+	case Command:
+		for _, hook := range c.OnCommand {
+			hook(ctx, c, mo)
+		}
+	case Reply:
+		for _, hook := range c.OnReply {
+			hook(ctx, c, mo)
+		}
+	}
+}
+
+func members(c *Connection, name string) []string {
+	nicks := []string{}
+
+	c.RLock()
+	defer c.RUnlock()
+
+	if channel, ok := c.channel(name); ok {
+		for _, nick := range channel.Status.Nicks {
+			nicks = append(nicks, nick.Name)
+		}
+	}
+
+	sort.Strings(nicks)
+
+	return nicks
+}
+
+func TestMembershipTracking(t *testing.T) {
+	c := newTestConnection(t, Config{Channels: []string{"#tenyks", "#other"}})
+	c.Status.CurrentNick = "tenyks"
+
+	dispatch(t, c, ":tenyks!~tenyks@example.com JOIN #tenyks")
+	dispatch(t, c, ":tenyks!~tenyks@example.com JOIN :#other")
+	dispatch(t, c, ":irc.test 353 tenyks = #tenyks :tenyks @alice +bob")
+	dispatch(t, c, ":irc.test 353 tenyks = #tenyks :carol")
+	dispatch(t, c, ":irc.test 366 tenyks #tenyks :End of /NAMES list.")
+	dispatch(t, c, ":irc.test 353 tenyks = #other :tenyks alice")
+	dispatch(t, c, ":irc.test 366 tenyks #other :End of /NAMES list.")
+
+	require.Equal(t, []string{"alice", "bob", "carol", "tenyks"}, members(c, "#tenyks"))
+
+	dispatch(t, c, ":dave!~dave@example.com JOIN #tenyks")
+	dispatch(t, c, ":bob!~bob@example.com PART #tenyks :bye")
+	dispatch(t, c, ":alice!~alice@example.com NICK Alicia")
+	dispatch(t, c, ":alice!~alice@example.com KICK #tenyks carol :behave")
+
+	require.Equal(t, []string{"Alicia", "dave", "tenyks"}, members(c, "#tenyks"))
+	require.Equal(t, []string{"Alicia", "tenyks"}, members(c, "#other"))
+
+	dispatch(t, c, ":Alicia!~alice@example.com QUIT :gone")
+
+	require.Equal(t, []string{"dave", "tenyks"}, members(c, "#tenyks"))
+	require.Equal(t, []string{"tenyks"}, members(c, "#other"))
+
+	t.Run("names replaces stale members", func(t *testing.T) {
+		dispatch(t, c, ":irc.test 353 tenyks = #tenyks :tenyks erin")
+		require.Equal(t, []string{"dave", "tenyks"}, members(c, "#tenyks"))
+
+		dispatch(t, c, ":irc.test 366 tenyks #tenyks :End of /NAMES list.")
+		require.Equal(t, []string{"erin", "tenyks"}, members(c, "#tenyks"))
+	})
+
+	t.Run("kicked", func(t *testing.T) {
+		dispatch(t, c, ":erin!~erin@example.com KICK #tenyks tenyks :out")
+
+		channel := c.channels["#tenyks"]
+		require.Equal(t, ChannelStatusErr, channel.Status.Status)
+		require.Equal(t, "kicked by erin: out", channel.Status.Message)
+		require.Empty(t, channel.Status.Nicks)
+	})
+
+	t.Run("parted", func(t *testing.T) {
+		dispatch(t, c, ":tenyks!~tenyks@example.com PART #other")
+
+		channel := c.channels["#other"]
+		require.Equal(t, ChannelStatusParted, channel.Status.Status)
+		require.Empty(t, channel.Status.Nicks)
+	})
+}
@@ -67,36 +67,24 @@ func ParseMessage(raw string) (*Message, error) {
 			msg.TagsSection.RawTags = rawTags
 			raw = raw[index+1:]
 
-			for _, tag := range strings.Split(rawTags, ";") {
-				var key, value, vendor string
-
-				parts := strings.Split(tag, "=")
-				if len(parts) > 2 {
-					return nil, errors.New("failed to parse message: invalid tag format")
+			for _, rawTag := range strings.Split(rawTags, ";") {
+				if rawTag == "" {
+					continue
 				}
 
-				rawKey := parts[0]
-
-				if len(parts) > 1 {
-					value = parts[1]
+				// values can contain =, so only the first one separates the
+				// key from the value
+				name, value := rawTag, ""
+				if index := strings.IndexByte(rawTag, '='); index != -1 {
+					name, value = rawTag[:index], unescapeTagValue(rawTag[index+1:])
 				}
 
-				if rawKey[0] == '+' {
-					rawKey = rawKey[1:]
-
-					if index := strings.Index(rawKey, "/"); index != -1 {
-						vendor = rawKey[:index]
-						rawKey = rawKey[index+1:]
-					}
+				tag := NewTag(name, value)
+				if tag.Key == "" {
+					return nil, errors.New("failed to parse message: invalid tag format")
 				}
 
-				key = rawKey
-
-				msg.TagsSection.Tags = append(msg.TagsSection.Tags, &Tag{
-					Key:    key,
-					Value:  value,
-					Vendor: vendor,
-				})
+				msg.TagsSection.Tags = append(msg.TagsSection.Tags, tag)
 			}
 		} else {
 			return nil, errors.New("failed to parse message: invalid message format")
@@ -144,6 +132,7 @@ func ParseMessage(raw string) (*Message, error) {
 	// trailing parameter as a string, so we need to store the trailing param as well.
 	if len(parts) == 2 {
 		msg.Trail = parts[1]
+		msg.EmptyTrail = msg.Trail == ""
 	}
 
 	msg.Command = params[0]
@@ -189,6 +178,10 @@ type Message struct {
 	// Trail is a parameter that starts with : used as a syntactic trick to allow
 	// a parameter to have a <SPACE> character.
 	Trail string
+	// EmptyTrail is true when the message has a trailing parameter that is
+	// empty. An empty Trail is left out when encoding otherwise, which some
+	// commands, like TOPIC, treat differently.
+	EmptyTrail bool
 	// CreatedAt is when we recieved the message and parsed it.
 	CreatedAt time.Time
 	// RawMessage is the full unaltered message.
@@ -207,10 +200,145 @@ type TagsSection struct {
 type Tag struct {
 	// Key is the required tag key
 	Key string
-	// Value is an optional tag value
+	// Value is an optional tag value. It's kept unescaped and escaped again
+	// when the message is encoded.
 	Value string
 	// Vendor is an optional vendor identifier
 	Vendor string
+	// ClientOnly is true for tags with a + prefix. Servers pass these between
+	// clients without looking at them.
+	ClientOnly bool
+}
+
+// NewTag returns the tag for a full tag name, like account or
+// +example.com/foo, with value.
+func NewTag(name, value string) *Tag {
+	tag := &Tag{Value: value}
+
+	if strings.HasPrefix(name, "+") {
+		tag.ClientOnly = true
+		name = name[1:]
+	}
+
+	if index := strings.IndexByte(name, '/'); index != -1 {
+		tag.Vendor = name[:index]
+		name = name[index+1:]
+	}
+
+	tag.Key = name
+
+	return tag
+}
+
+// Name returns the full tag name, with the client-only prefix and vendor.
+func (t *Tag) Name() string {
+	name := t.Key
+
+	if t.Vendor != "" {
+		name = t.Vendor + "/" + name
+	}
+
+	if t.ClientOnly {
+		name = "+" + name
+	}
+
+	return name
+}
+
+// String returns the tag the way it's sent to the server.
+func (t *Tag) String() string {
+	if t.Value == "" {
+		return t.Name()
+	}
+
+	return t.Name() + "=" + tagValueEscaper.Replace(t.Value)
+}
+
+// tagValueEscaper escapes the characters that can't appear in a tag value.
+var tagValueEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\:`,
+	" ", `\s`,
+	"\r", `\r`,
+	"\n", `\n`,
+)
+
+// unescapeTagValue reverses tagValueEscaper. A backslash before any other
+// character is dropped, and so is one at the end of the value.
+func unescapeTagValue(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+
+	var b strings.Builder
+
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			b.WriteByte(value[i])
+
+			continue
+		}
+
+		i++
+		if i == len(value) {
+			break
+		}
+
+		switch value[i] {
+		case ':':
+			b.WriteByte(';')
+		case 's':
+			b.WriteByte(' ')
+		case 'r':
+			b.WriteByte('\r')
+		case 'n':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+
+	return b.String()
+}
+
+// Tag returns the value of the tag with the full name, like account or
+// +draft/reply, and whether the message has it. If the tag is repeated the
+// last value wins.
+func (m *Message) Tag(name string) (string, bool) {
+	if m.TagsSection == nil {
+		return "", false
+	}
+
+	var (
+		value string
+		found bool
+	)
+
+	for _, tag := range m.TagsSection.Tags {
+		if tag.Name() == name {
+			value, found = tag.Value, true
+		}
+	}
+
+	return value, found
+}
+
+// SetTag sets the tag with the full name to value, replacing it if the
+// message already has it.
+func (m *Message) SetTag(name, value string) {
+	if m.TagsSection == nil {
+		m.TagsSection = &TagsSection{}
+	}
+
+	for _, tag := range m.TagsSection.Tags {
+		if tag.Name() == name {
+			tag.Value = value
+
+			return
+		}
+	}
+
+	m.TagsSection.Tags = append(m.TagsSection.Tags, NewTag(name, value))
 }
 
 type PrefixSection struct {
@@ -96,3 +96,85 @@ func TestParseIRCMessage(t *testing.T) {
 		})
 	}
 }
+
+func TestMessageTags(t *testing.T) {
+	t.Parallel()
+
+	msg, err := ParseMessage(`@time=2021-01-02T03:04:05.678Z;+example.com/note=a\sb\:c\\d\r\ne=f;+draft/reply=abc;empty=;flag;odd=x\qy\ :alice!~alice@example.com PRIVMSG #tenyks :hi`)
+	require.NoError(t, err)
+
+	cases := []struct {
+		name  string
+		value string
+		found bool
+	}{
+		{name: "time", value: "2021-01-02T03:04:05.678Z", found: true},
+		{name: "+example.com/note", value: "a b;c\\d\r\ne=f", found: true},
+		{name: "+draft/reply", value: "abc", found: true},
+		{name: "draft/reply"},
+		{name: "empty", found: true},
+		{name: "flag", found: true},
+		{name: "odd", value: "xqy", found: true},
+		{name: "missing"},
+	}
+
+	for _, c := range cases {
+		value, found := msg.Tag(c.name)
+
+		require.Equal(t, c.found, found, c.name)
+		require.Equal(t, c.value, value, c.name)
+	}
+
+	note := msg.TagsSection.Tags[1]
+	require.True(t, note.ClientOnly)
+	require.Equal(t, "example.com", note.Vendor)
+	require.Equal(t, "note", note.Key)
+
+	t.Run("round trip", func(t *testing.T) {
+		cmd := NewPrivmsgCommand("#tenyks", "hi")
+		cmd.Message().TagsSection = msg.TagsSection
+
+		line, err := cmd.Encode()
+		require.NoError(t, err)
+
+		decoded, err := ParseMessage(line)
+		require.NoError(t, err)
+		require.Equal(t, msg.TagsSection.Tags, decoded.TagsSection.Tags)
+		require.Equal(t, "hi", decoded.Trail)
+	})
+
+	t.Run("last value wins", func(t *testing.T) {
+		msg, err := ParseMessage("@a=1;a=2 PING :x")
+		require.NoError(t, err)
+
+		value, _ := msg.Tag("a")
+		require.Equal(t, "2", value)
+	})
+
+	t.Run("set", func(t *testing.T) {
+		msg := &Message{Command: "TAGMSG", Params: []string{"#tenyks"}}
+		msg.SetTag("+draft/react", "👍")
+		msg.SetTag("+draft/reply", "abc")
+		msg.SetTag("+draft/react", "🎉")
+
+		line, err := NewRawMessageEncoder().Encode(msg)
+		require.NoError(t, err)
+		require.Equal(t, "@+draft/react=🎉;+draft/reply=abc TAGMSG #tenyks\r\n", line)
+	})
+}
+
+func TestEmptyTrail(t *testing.T) {
+	t.Parallel()
+
+	msg, err := ParseMessage(":alice!~alice@example.com TOPIC #tenyks :")
+	require.NoError(t, err)
+	require.True(t, msg.EmptyTrail)
+
+	line, err := NewRawMessageEncoder().Encode(&Message{Command: "TOPIC", Params: []string{"#tenyks"}, EmptyTrail: true})
+	require.NoError(t, err)
+	require.Equal(t, "TOPIC #tenyks :\r\n", line)
+
+	line, err = NewRawMessageEncoder().Encode(&Message{Command: "TOPIC", Params: []string{"#tenyks"}})
+	require.NoError(t, err)
+	require.Equal(t, "TOPIC #tenyks\r\n", line)
+}
@@ -27,13 +27,22 @@ type RawMessageEncoder struct{}
 func (e *RawMessageEncoder) Encode(msg *Message) (string, error) {
 	params := msg.Params
 
-	if msg.Trail != "" {
+	if msg.Trail != "" || msg.EmptyTrail {
 		params = append(params, fmt.Sprintf(":%s", msg.Trail))
 	}
 
 	msg.RawMsg = fmt.Sprintf("%s %s", msg.Command, strings.Join(params, " "))
 
-	// TODO encode tags
+	if msg.TagsSection != nil && len(msg.TagsSection.Tags) > 0 {
+		tags := make([]string, 0, len(msg.TagsSection.Tags))
+		for _, tag := range msg.TagsSection.Tags {
+			tags = append(tags, tag.String())
+		}
+
+		msg.TagsSection.RawTags = strings.Join(tags, ";")
+		msg.RawMsg = fmt.Sprintf("@%s %s", msg.TagsSection.RawTags, msg.RawMsg)
+	}
+
 	return fmt.Sprintf("%s\r\n", msg.RawMsg), nil
 }
 
@@ -56,23 +65,153 @@ func NewRawMessageDecoder() *RawMessageDecoder {
 	return &RawMessageDecoder{}
 }
 
-type tenyksChatMessageEncoder struct{}
+// targetPath returns the tenyks path for a channel or nick on this
+// connection, like /irc/freenode/#tenyks.
+func (c *Connection) targetPath(target string) string {
+	return path.Join("/irc", c.Name, target)
+}
+
+type tenyksChatMessageEncoder struct {
+	// account returns the services account of the sender of a message. The
+	// account is left empty if it's nil.
+	account func(*Message) string
+	// serverTime is true when the server-time capability is enabled, so
+	// messages are stamped with the time the server saw them instead of
+	// when we did.
+	serverTime bool
+}
+
+// chatMessageEncoder returns the encoder used for PRIVMSGs and NOTICEs we
+// receive.
+func (c *Connection) chatMessageEncoder() *tenyksChatMessageEncoder {
+	return &tenyksChatMessageEncoder{
+		account:    c.senderAccount,
+		serverTime: c.HasCapability("server-time"),
+	}
+}
+
+func (tme *tenyksChatMessageEncoder) senderAccount(msg *Message) string {
+	if tme.account == nil {
+		return ""
+	}
+
+	return tme.account(msg)
+}
+
+// timestamp returns when msg was sent. With server-time it's the time tag,
+// which is also right for messages a bouncer plays back later.
+func (tme *tenyksChatMessageEncoder) timestamp(msg *Message) time.Time {
+	if tme.serverTime {
+		if value, ok := msg.Tag("time"); ok {
+			if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+				return t
+			}
+		}
+	}
+
+	return time.Now()
+}
+
+// messageID returns the msgid tag, or an empty string if msg doesn't have
+// one.
+func messageID(msg *Message) string {
+	id, _ := msg.Tag("msgid")
+
+	return id
+}
+
+// replyTo returns the msgid of the message msg replies to, or an empty
+// string if it isn't a reply.
+func replyTo(msg *Message) string {
+	id, _ := msg.Tag(replyTag)
+
+	return id
+}
 
 func (tme *tenyksChatMessageEncoder) Encode(cmd *PrivmsgCommand) (message.Message, error) {
 	tmsg := &message.ChatMessage{
 		DestinationPath: "/",
 		OriginPath:      "/",
 		Content:         cmd.Message().Trail,
-		Timestamp:       time.Now(),
+		Account:         tme.senderAccount(cmd.Message()),
+		MessageID:       messageID(cmd.Message()),
+		ReplyTo:         replyTo(cmd.Message()),
+		Timestamp:       tme.timestamp(cmd.Message()),
+	}
+
+	if action, ok := cmd.CTCP(); ok && action.Command == CTCPAction {
+		tmsg.Action = true
+		tmsg.Content = action.Params
 	}
 
 	return tmsg, nil
 }
 
-type tenyksChatMessageDecoder struct{}
+// EncodeNotice turns a NOTICE into a chat message marked as a notice.
+// Services should never answer those automatically.
+func (tme *tenyksChatMessageEncoder) EncodeNotice(cmd *NoticeCommand) (message.Message, error) {
+	tmsg := &message.ChatMessage{
+		DestinationPath: "/",
+		OriginPath:      "/",
+		Content:         cmd.Message().Trail,
+		Account:         tme.senderAccount(cmd.Message()),
+		Notice:          true,
+		MessageID:       messageID(cmd.Message()),
+		ReplyTo:         replyTo(cmd.Message()),
+		Timestamp:       tme.timestamp(cmd.Message()),
+	}
+
+	return tmsg, nil
+}
+
+// EncodeReaction turns a TAGMSG reacting to a message into a chat message
+// with the reaction and the id of the message it reacts to.
+func (tme *tenyksChatMessageEncoder) EncodeReaction(cmd *TagmsgCommand) (message.Message, error) {
+	reaction, _ := cmd.Message().Tag(reactTag)
+
+	tmsg := &message.ChatMessage{
+		DestinationPath: "/",
+		OriginPath:      "/",
+		Account:         tme.senderAccount(cmd.Message()),
+		MessageID:       messageID(cmd.Message()),
+		ReplyTo:         replyTo(cmd.Message()),
+		Reaction:        reaction,
+		Timestamp:       tme.timestamp(cmd.Message()),
+	}
 
-func (tmd *tenyksChatMessageDecoder) Decode(msg message.Message) (*PrivmsgCommand, error) {
-	var cmd *PrivmsgCommand
+	return tmsg, nil
+}
+
+// tenyksChatMessageDecoder turns tenyks messages into PRIVMSGs. Content that
+// doesn't fit in a single IRC line is split over several.
+type tenyksChatMessageDecoder struct {
+	// budget returns how many bytes of text fit in a PRIVMSG to target. If
+	// it's nil, a budget that assumes the longest likely prefix is used.
+	budget func(target string) int
+	// clientTags is true when the message-tags capability is enabled, so
+	// replies and reactions can be sent with +draft/reply and +draft/react.
+	clientTags bool
+	// replySender returns the nick that sent a message, for replies that
+	// have to fall back to "nick: " without client tags. If it's nil, those
+	// replies are sent as they are.
+	replySender func(msgid string) string
+}
+
+func (tmd *tenyksChatMessageDecoder) sender(msgid string) string {
+	if tmd.replySender == nil {
+		return ""
+	}
+
+	return tmd.replySender(msgid)
+}
+
+// Decode returns the commands that send msg. Actions are sent as CTCP
+// ACTIONs and notices as NOTICEs, everything else is a PRIVMSG. Replies are
+// tagged with the message they reply to and reactions are sent as TAGMSGs.
+// Without client tags both go out as PRIVMSGs addressed to the sender of
+// the original message.
+func (tmd *tenyksChatMessageDecoder) Decode(msg message.Message) ([]Command, error) {
+	var cmds []Command
 
 	switch msg.(type) {
 	case *message.ChatMessage:
@@ -80,10 +219,49 @@ func (tmd *tenyksChatMessageDecoder) Decode(msg message.Message) (*PrivmsgComman
 
 		_, target := path.Split(theirs.DestinationPath)
 
-		cmd = NewPrivmsgCommand(target, theirs.Content)
+		budget := tmd.budget
+		if budget == nil {
+			budget = func(target string) int {
+				return lineBudget(maxLineLength, strings.Repeat("x", maxPrefixLength), "PRIVMSG", target)
+			}
+		}
+
+		if theirs.Reaction != "" && theirs.ReplyTo == "" {
+			return nil, errors.New("reaction has no message to react to")
+		}
+
+		content := theirs.Content
+		if theirs.ReplyTo != "" && !tmd.clientTags && !theirs.Action {
+			if theirs.Reaction != "" {
+				content = theirs.Reaction
+			}
+
+			content = addressed(tmd.sender(theirs.ReplyTo), content)
+		}
+
+		switch {
+		case theirs.Reaction != "" && tmd.clientTags:
+			cmds = []Command{NewReactionCommand(target, theirs.ReplyTo, theirs.Reaction)}
+		case theirs.Action:
+			cmds = newActionCommands(target, content, budget(target))
+		case theirs.Notice:
+			cmds = newNoticeCommands(target, content, budget(target))
+		default:
+			cmds = newPrivmsgCommands(target, content, budget(target))
+		}
+
+		if theirs.ReplyTo != "" && tmd.clientTags {
+			for _, cmd := range cmds {
+				cmd.Message().SetTag(replyTag, theirs.ReplyTo)
+			}
+		}
 	default:
 		return nil, errors.New("unexpected message type")
 	}
 
-	return cmd, nil
+	if len(cmds) == 0 {
+		return nil, errors.New("message has no content")
+	}
+
+	return cmds, nil
 }
@@ -0,0 +1,41 @@
+package irc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kyleterry/tenyks/pkg/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatMessageServerTime(t *testing.T) {
+	c := newTestConnection(t, Config{})
+	c.Status.CurrentNick = "tenyks"
+
+	msgs := []*message.ChatMessage{}
+	c.RegisterMessageHandler(func(msg message.Message) {
+		msgs = append(msgs, msg.(*message.ChatMessage))
+	})
+
+	line := "@time=2021-01-02T03:04:05.678Z;msgid=abc123 :alice!~alice@example.com PRIVMSG #tenyks :tenyks: hi"
+
+	before := time.Now()
+	dispatch(t, c, line)
+
+	c.Status.Capabilities["server-time"] = ""
+	dispatch(t, c, line)
+	dispatch(t, c, "@time=yesterday :alice!~alice@example.com NOTICE tenyks :hi")
+
+	require.Len(t, msgs, 3)
+
+	// without the capability the tag isn't trusted
+	require.False(t, msgs[0].Timestamp.Before(before))
+	require.Equal(t, "abc123", msgs[0].MessageID)
+
+	require.Equal(t, time.Date(2021, 1, 2, 3, 4, 5, 678000000, time.UTC), msgs[1].Timestamp)
+	require.Equal(t, "abc123", msgs[1].MessageID)
+
+	require.True(t, msgs[2].Notice)
+	require.False(t, msgs[2].Timestamp.Before(before))
+	require.Empty(t, msgs[2].MessageID)
+}
@@ -0,0 +1,298 @@
+package irc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/kyleterry/tenyks/pkg/logger"
+)
+
+// ModeChange is a single mode being set or unset.
+type ModeChange struct {
+	Add   bool
+	Mode  byte
+	Param string
+}
+
+// ParseModes splits a mode string like +ov-k into changes, pairing modes
+// with their parameters using the server's PREFIX and CHANMODES. Modes the
+// server didn't tell us about are assumed to not take a parameter.
+func (f *ServerFeatures) ParseModes(modes string, params []string) []ModeChange {
+	changes := []ModeChange{}
+	add := true
+
+	for i := 0; i < len(modes); i++ {
+		mode := modes[i]
+
+		switch mode {
+		case '+':
+			add = true
+
+			continue
+		case '-':
+			add = false
+
+			continue
+		}
+
+		change := ModeChange{Add: add, Mode: mode}
+
+		if f.modeTakesParam(mode, add) && len(params) > 0 {
+			change.Param, params = params[0], params[1:]
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+func (f *ServerFeatures) modeTakesParam(mode byte, add bool) bool {
+	switch {
+	case strings.IndexByte(f.PrefixModes, mode) != -1:
+		return true
+	case strings.IndexByte(f.ChanModes.List, mode) != -1:
+		return true
+	case strings.IndexByte(f.ChanModes.Always, mode) != -1:
+		return true
+	case strings.IndexByte(f.ChanModes.OnSet, mode) != -1:
+		return add
+	}
+
+	return false
+}
+
+func (f *ServerFeatures) isListMode(mode byte) bool {
+	return strings.IndexByte(f.ChanModes.List, mode) != -1
+}
+
+func (f *ServerFeatures) isPrefixMode(mode byte) bool {
+	return strings.IndexByte(f.PrefixModes, mode) != -1
+}
+
+// PrefixModesFor returns the prefix modes for the prefixes in front of a
+// name from NAMES, ordered from most to least powerful.
+func (f *ServerFeatures) PrefixModesFor(prefixes string) string {
+	modes := []byte{}
+
+	for i := 0; i < len(f.PrefixChars) && i < len(f.PrefixModes); i++ {
+		if strings.IndexByte(prefixes, f.PrefixChars[i]) != -1 {
+			modes = append(modes, f.PrefixModes[i])
+		}
+	}
+
+	return string(modes)
+}
+
+// setPrefixMode adds or removes a prefix mode from modes, keeping them
+// ordered from most to least powerful.
+func (f *ServerFeatures) setPrefixMode(modes string, mode byte, add bool) string {
+	ordered := []byte{}
+
+	for i := 0; i < len(f.PrefixModes); i++ {
+		m := f.PrefixModes[i]
+
+		has := strings.IndexByte(modes, m) != -1
+		if m == mode {
+			has = add
+		}
+
+		if has {
+			ordered = append(ordered, m)
+		}
+	}
+
+	return string(ordered)
+}
+
+// AtLeast returns true if any of modes is mode or a more powerful prefix
+// mode. On a server with PREFIX=(qaohv)~&@%+, AtLeast("a", 'o') is true.
+func (f *ServerFeatures) AtLeast(modes string, mode byte) bool {
+	rank := strings.IndexByte(f.PrefixModes, mode)
+	if rank == -1 {
+		return strings.IndexByte(modes, mode) != -1
+	}
+
+	for i := 0; i < len(modes); i++ {
+		if r := strings.IndexByte(f.PrefixModes, modes[i]); r != -1 && r <= rank {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasPrivilege returns true if nick has mode, or a more powerful prefix mode,
+// in channel.
+func (c *Connection) HasPrivilege(channel, nick string, mode byte) bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	ch, ok := c.channel(channel)
+	if !ok {
+		return false
+	}
+
+	member, ok := ch.Status.Nicks[c.fold(nick)]
+	if !ok {
+		return false
+	}
+
+	return c.features.AtLeast(member.Modes, mode)
+}
+
+// IsOp returns true if nick is a channel operator, or higher, in channel.
+func (c *Connection) IsOp(channel, nick string) bool {
+	return c.HasPrivilege(channel, nick, 'o')
+}
+
+// applyModes updates a channel with mode changes. The caller must hold the
+// connection's lock.
+func (c *Connection) applyModes(channel *Channel, changes []ModeChange) {
+	for _, change := range changes {
+		switch {
+		case c.features.isPrefixMode(change.Mode):
+			if member, ok := channel.Status.Nicks[c.fold(change.Param)]; ok {
+				member.Modes = c.features.setPrefixMode(member.Modes, change.Mode, change.Add)
+			}
+		case c.features.isListMode(change.Mode):
+			channel.Status.Lists[change.Mode] = updateList(channel.Status.Lists[change.Mode], change.Param, change.Add)
+		case change.Add:
+			channel.Status.Modes[change.Mode] = change.Param
+		default:
+			delete(channel.Status.Modes, change.Mode)
+		}
+	}
+}
+
+func updateList(list []string, mask string, add bool) []string {
+	for i, m := range list {
+		if m == mask {
+			if add {
+				return list
+			}
+
+			return append(list[:i:i], list[i+1:]...)
+		}
+	}
+
+	if add {
+		list = append(list, mask)
+	}
+
+	return list
+}
+
+// defaultModeTracker applies MODE changes to our channels.
+func defaultModeTracker(ctx context.Context, c *Connection, command Command) error {
+	cmd, ok := command.(*ModeCommand)
+	if !ok {
+		return nil
+	}
+
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+
+	features := c.Features()
+
+	// user modes aren't tracked
+	if !features.IsChannel(cmd.Target()) {
+		return nil
+	}
+
+	changes := cmd.Changes(features)
+
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		if channel, ok := conn.channel(cmd.Target()); ok {
+			conn.applyModes(channel, changes)
+		}
+	})
+
+	c.log.Debug("channel modes changed",
+		logger.Param{Key: "channel", Value: cmd.Target()},
+		logger.Param{Key: "by", Value: prefixNick(cmd.Message())})
+
+	return nil
+}
+
+// defaultChannelModeRequester asks for a channel's modes and ban list after
+// we join it. RPL_CHANNELMODEIS and the RPL_BANLIST replies fill them in.
+func defaultChannelModeRequester(ctx context.Context, c *Connection, command Command) error {
+	cmd, ok := command.(*JoinCommand)
+	if !ok || !c.isSelf(prefixNick(cmd.Message())) {
+		return nil
+	}
+
+	if err := c.enqueueRestoreCommand(NewModeCommand(cmd.Channel())); err != nil {
+		return err
+	}
+
+	return c.enqueueRestoreCommand(NewModeCommand(cmd.Channel(), "b"))
+}
+
+// defaultChannelModeReplyHandler records the modes and lists the server sends
+// in reply to MODE queries. List entries are collected until the end of the
+// list and then replace what we had.
+func defaultChannelModeReplyHandler(ctx context.Context, c *Connection, reply Reply) error {
+	switch r := reply.(type) {
+	case *ChannelModeIsReply:
+		if err := r.Validate(); err != nil {
+			return err
+		}
+
+		changes := r.Modes(c.Features())
+
+		c.WithWriteLock(ctx, func(conn *Connection) {
+			if channel, ok := conn.channel(r.Channel()); ok {
+				channel.Status.Modes = map[byte]string{}
+				conn.applyModes(channel, changes)
+			}
+		})
+	case *ListModeReply:
+		if err := r.Validate(); err != nil {
+			return err
+		}
+
+		c.WithWriteLock(ctx, func(conn *Connection) {
+			if _, ok := conn.channel(r.Channel()); !ok {
+				return
+			}
+
+			key := conn.fold(r.Channel())
+
+			lists, ok := conn.pendingLists[key]
+			if !ok {
+				lists = map[byte][]string{}
+				conn.pendingLists[key] = lists
+			}
+
+			lists[r.Mode()] = updateList(lists[r.Mode()], r.Mask(), true)
+		})
+	case *EndOfListModeReply:
+		if err := r.Validate(); err != nil {
+			return err
+		}
+
+		c.WithWriteLock(ctx, func(conn *Connection) {
+			channel, ok := conn.channel(r.Channel())
+			if !ok {
+				return
+			}
+
+			key := conn.fold(r.Channel())
+
+			channel.Status.Lists[r.Mode()] = conn.pendingLists[key][r.Mode()]
+
+			if lists, ok := conn.pendingLists[key]; ok {
+				delete(lists, r.Mode())
+
+				if len(lists) == 0 {
+					delete(conn.pendingLists, key)
+				}
+			}
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package irc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseModes(t *testing.T) {
+	f := NewServerFeatures()
+	f.apply([]string{"PREFIX=(qaohv)~&@%+", "CHANMODES=beI,k,l,imnpst"})
+
+	changes := f.ParseModes("+ovk-lb+m", []string{"alice", "bob", "secret", "*!*@spam"})
+
+	require.Equal(t, []ModeChange{
+		{Add: true, Mode: 'o', Param: "alice"},
+		{Add: true, Mode: 'v', Param: "bob"},
+		{Add: true, Mode: 'k', Param: "secret"},
+		{Add: false, Mode: 'l'},
+		{Add: false, Mode: 'b', Param: "*!*@spam"},
+		{Add: true, Mode: 'm'},
+	}, changes)
+
+	require.Equal(t, "qo", f.PrefixModesFor("@~"))
+	require.Equal(t, "ov", f.setPrefixMode("v", 'o', true))
+	require.Equal(t, "v", f.setPrefixMode("ov", 'o', false))
+
+	require.True(t, f.AtLeast("a", 'o'))
+	require.True(t, f.AtLeast("ov", 'o'))
+	require.False(t, f.AtLeast("hv", 'o'))
+	require.False(t, f.AtLeast("", 'v'))
+}
+
+func TestChannelModeTracking(t *testing.T) {
+	c := newTestConnection(t, Config{Channels: []string{"#tenyks"}})
+	c.Status.CurrentNick = "tenyks"
+
+	dispatch(t, c, ":irc.test 005 tenyks PREFIX=(qaohv)~&@%+ CHANMODES=beI,k,l,imnpst :are supported by this server")
+	dispatch(t, c, ":tenyks!~tenyks@example.com JOIN #tenyks")
+
+	// joining asks for the channel modes and ban list, and who is in there
+	require.Equal(t, []string{"MODE #tenyks\r\n", "MODE #tenyks b\r\n", "WHO #tenyks\r\n"}, drainCommands(t, c))
+
+	dispatch(t, c, ":irc.test 353 tenyks = #tenyks :tenyks ~@alice +bob carol")
+	dispatch(t, c, ":irc.test 366 tenyks #tenyks :End of /NAMES list.")
+	dispatch(t, c, ":irc.test 324 tenyks #tenyks +ntkl secret 50")
+	dispatch(t, c, ":irc.test 367 tenyks #tenyks *!*@spam alice 1600000000")
+	dispatch(t, c, ":irc.test 367 tenyks #tenyks *!*@eggs alice 1600000000")
+	dispatch(t, c, ":irc.test 368 tenyks #tenyks :End of channel ban list")
+
+	status := c.channels["#tenyks"].Status
+
+	require.Equal(t, "qo", status.Nicks["alice"].Modes)
+	require.Equal(t, "v", status.Nicks["bob"].Modes)
+	require.Equal(t, "secret", status.Key())
+	require.Equal(t, 50, status.Limit())
+	require.False(t, status.IsModerated())
+	require.Equal(t, []string{"*!*@spam", "*!*@eggs"}, status.Bans())
+
+	require.True(t, c.IsOp("#tenyks", "Alice"))
+	require.False(t, c.IsOp("#tenyks", "bob"))
+	require.True(t, c.HasPrivilege("#tenyks", "bob", 'v'))
+
+	dispatch(t, c, ":alice!~alice@example.com MODE #tenyks +om-k+bI carol * *!*@ham *!*@friend")
+	dispatch(t, c, ":alice!~alice@example.com MODE #tenyks -vb bob *!*@spam")
+
+	require.True(t, c.IsOp("#tenyks", "carol"))
+	require.False(t, c.HasPrivilege("#tenyks", "bob", 'v'))
+	require.True(t, status.IsModerated())
+	require.Empty(t, status.Key())
+	require.Equal(t, []string{"*!*@eggs", "*!*@ham"}, status.Bans())
+	require.Equal(t, []string{"*!*@friend"}, status.Invites())
+
+	// the modes can come as the trail
+	dispatch(t, c, ":irc.test 324 tenyks #tenyks :+ntl 20")
+
+	require.False(t, status.IsModerated())
+	require.Equal(t, 20, status.Limit())
+	require.Empty(t, status.Key())
+
+	// our own user modes are ignored
+	dispatch(t, c, ":tenyks MODE tenyks :+i")
+}
@@ -1,11 +1,391 @@
 package irc
 
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kyleterry/tenyks/pkg/logger"
+)
+
+// DefaultNickRegainInterval is how often we check if the primary nick is
+// free when the server doesn't support MONITOR.
+const DefaultNickRegainInterval = time.Minute
+
+// Nick is a member of a channel. Ident and Host are learned from the
+// member's JOIN, the rest comes from WHO.
 type Nick struct {
 	Name string
+	// Modes are the member's prefix modes in the channel, like o for op and
+	// v for voice, ordered from most to least powerful.
+	Modes    string
+	Ident    string
+	Host     string
+	RealName string
+	// Account is the services account the member is logged into. It's empty
+	// if they aren't logged in or we don't know.
+	Account string
+	Away    bool
+	// Server is the server the member is connected to.
+	Server string
 }
 
+// Hostmask returns the member's nick!ident@host, or just the nick if we
+// don't know their ident and host yet.
+func (n *Nick) Hostmask() string {
+	if n.Ident == "" || n.Host == "" {
+		return n.Name
+	}
+
+	return n.Name + "!" + n.Ident + "@" + n.Host
+}
+
+// HasMode returns true if the member has the prefix mode.
+func (n *Nick) HasMode(mode byte) bool {
+	return strings.IndexByte(n.Modes, mode) != -1
+}
+
+// NickManager picks the nick to use during registration and keeps track of
+// which nicks the server rejected. The first configured nick is the primary
+// nick. When every configured nick is rejected, fallbacks are generated from
+// the primary by appending underscores and then numbers.
 type NickManager struct {
 	nicks      []string
 	deadletter []string
 	current    string
+	// fallbacks is the number of generated nicks we have tried
+	fallbacks int
+	// monitor is true if the server supports MONITOR, so we can be told when
+	// the primary nick is free instead of polling with ISON.
+	monitor bool
+	// caseMapping is used to compare nicks the way the server does
+	caseMapping CaseMapping
+
+	sync.Mutex
+}
+
+func NewNickManager(nicks []string) *NickManager {
+	return &NickManager{
+		nicks:       nicks,
+		caseMapping: CaseMappingRFC1459,
+	}
+}
+
+// Primary returns the nick we want to have.
+func (nm *NickManager) Primary() string {
+	if len(nm.nicks) == 0 {
+		return ""
+	}
+
+	return nm.nicks[0]
+}
+
+// Current returns the nick the server last confirmed for us.
+func (nm *NickManager) Current() string {
+	nm.Lock()
+	defer nm.Unlock()
+
+	return nm.current
+}
+
+// Next returns the next nick to try. It's the first configured nick that
+// hasn't been rejected, or a generated fallback once they all have.
+func (nm *NickManager) Next() string {
+	nm.Lock()
+	defer nm.Unlock()
+
+	for _, nick := range nm.nicks {
+		if !nm.rejected(nick) {
+			return nick
+		}
+	}
+
+	for {
+		nm.fallbacks++
+
+		nick := fallbackNick(nm.Primary(), nm.fallbacks)
+		if !nm.rejected(nick) {
+			return nick
+		}
+	}
+}
+
+// Reject records that the server refused nick.
+func (nm *NickManager) Reject(nick string) {
+	nm.Lock()
+	defer nm.Unlock()
+
+	if !nm.rejected(nick) {
+		nm.deadletter = append(nm.deadletter, nick)
+	}
+}
+
+// Confirm records that the server has changed our nick to nick.
+func (nm *NickManager) Confirm(nick string) {
+	nm.Lock()
+	defer nm.Unlock()
+
+	nm.current = nick
+}
+
+// HasPrimary returns true if the current nick is the primary nick.
+func (nm *NickManager) HasPrimary() bool {
+	nm.Lock()
+	defer nm.Unlock()
+
+	return nm.current != "" && nm.caseMapping.Equal(nm.current, nm.Primary())
+}
+
+// Reset forgets the rejected nicks and current nick. It's called when we
+// disconnect since a new registration starts from the primary nick again.
+func (nm *NickManager) Reset() {
+	nm.Lock()
+	defer nm.Unlock()
+
+	nm.deadletter = nil
+	nm.current = ""
+	nm.fallbacks = 0
+	nm.monitor = false
+}
+
+// SetCaseMapping sets how nicks are compared.
+func (nm *NickManager) SetCaseMapping(cm CaseMapping) {
+	nm.Lock()
+	defer nm.Unlock()
+
+	nm.caseMapping = cm
+}
+
+// IsPrimary returns true if nick is the primary nick.
+func (nm *NickManager) IsPrimary(nick string) bool {
+	nm.Lock()
+	defer nm.Unlock()
+
+	return nm.caseMapping.Equal(nick, nm.Primary())
+}
+
+// SetMonitor records whether the server supports MONITOR.
+func (nm *NickManager) SetMonitor(supported bool) {
+	nm.Lock()
+	defer nm.Unlock()
+
+	nm.monitor = supported
+}
+
+func (nm *NickManager) supportsMonitor() bool {
+	nm.Lock()
+	defer nm.Unlock()
+
+	return nm.monitor
+}
+
+func (nm *NickManager) rejected(nick string) bool {
+	for _, n := range nm.deadletter {
+		if nm.caseMapping.Equal(n, nick) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fallbackNick generates the nth fallback for primary: tenyks_, tenyks__,
+// tenyks1, tenyks2 and so on.
+func fallbackNick(primary string, n int) string {
+	if n <= 2 {
+		return primary + strings.Repeat("_", n)
+	}
+
+	return fmt.Sprintf("%s%d", primary, n-2)
+}
+
+// defaultNickCollisionHandler picks another nick when the server rejects the
+// one we asked for during registration. After registration a rejected nick
+// means an attempt to regain the primary nick failed, and we keep the one we
+// have.
+func defaultNickCollisionHandler(ctx context.Context, c *Connection, reply Reply) error {
+	var rejected string
+
+	switch r := reply.(type) {
+	case *ErrErroneusNicknameReply:
+		rejected = r.Nick()
+	case *ErrNickInUseReply:
+		rejected = r.Nick()
+	case *ErrNickCollisionReply:
+		rejected = r.Nick()
+	case *ErrUnavailResourceReply:
+		if c.Features().IsChannel(r.Nick()) {
+			return nil
+		}
+
+		rejected = r.Nick()
+	default:
+		return nil
+	}
+
+	var registered bool
+
+	c.WithReadLock(ctx, func(conn *Connection) {
+		registered = conn.Status.Connected
+	})
+
+	c.nickManager.Reject(rejected)
+
+	if registered {
+		c.log.Debug("nick unavailable", logger.Param{Key: "nick", Value: rejected})
+
+		return nil
+	}
+
+	next := c.nickManager.Next()
+
+	c.log.Info("nick rejected, trying another",
+		logger.Param{Key: "rejected", Value: rejected},
+		logger.Param{Key: "nick", Value: next})
+
+	return c.EnqueueCommand(NewNickCommand(next))
+}
+
+// defaultNickChangeHandler updates our nick when the server confirms a
+// change with a NICK message.
+func defaultNickChangeHandler(ctx context.Context, c *Connection, command Command) error {
+	cmd, ok := command.(*NickCommand)
+	if !ok {
+		return nil
+	}
+
+	msg := cmd.Message()
+
+	if msg.PrefixSection == nil || !c.isSelf(msg.PrefixSection.Nick) {
+		return nil
+	}
+
+	nick := cmd.Nick()
+
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		conn.Status.CurrentNick = nick
+	})
+
+	c.nickManager.Confirm(nick)
+
+	if c.nickManager.IsPrimary(nick) && c.nickManager.supportsMonitor() {
+		return c.enqueueRestoreCommand(NewMonitorCommand("-", nick))
+	}
+
+	return nil
+}
+
+// defaultNickRegainFunc starts trying to get the primary nick back if we
+// registered with a different one. The choice between MONITOR and ISON
+// polling is made on the first tick: with MONITOR the server tells us when
+// the nick is free, otherwise we keep polling with ISON.
+func defaultNickRegainFunc(ctx context.Context, c *Connection) error {
+	if c.nickManager.HasPrimary() {
+		return nil
+	}
+
+	sctx := c.sessionContext()
+	primary := c.nickManager.Primary()
+
+	go func() {
+		ticker := time.NewTicker(c.nickRegainInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-sctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if c.nickManager.HasPrimary() {
+				return
+			}
+
+			if c.nickManager.supportsMonitor() {
+				if err := c.EnqueueCommand(NewMonitorCommand("+", primary)); err != nil {
+					c.log.Error("failed to monitor nick", logger.Param{Key: "error", Value: err})
+				}
+
+				return
+			}
+
+			if err := c.sendRegainProbe(sctx, primary); err != nil {
+				c.log.Error("failed to check nick", logger.Param{Key: "error", Value: err})
+			}
+		}
+	}()
+
+	return nil
+}
+
+// sendRegainProbe asks the server with ISON if the primary nick is online.
+// Only the answer to our own ISON is used, services may send their own.
+func (c *Connection) sendRegainProbe(ctx context.Context, primary string) error {
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		conn.regainProbe = true
+	})
+
+	return c.EnqueueCommand(NewIsonCommand(primary))
+}
+
+// defaultNickRegainHandler asks for the primary nick when ISON or MONITOR
+// tell us it's free.
+func defaultNickRegainHandler(ctx context.Context, c *Connection, reply Reply) error {
+	if c.nickManager.HasPrimary() {
+		return nil
+	}
+
+	primary := c.nickManager.Primary()
+
+	switch r := reply.(type) {
+	case *IsonReply:
+		var probed bool
+
+		c.WithWriteLock(ctx, func(conn *Connection) {
+			probed = conn.regainProbe
+			conn.regainProbe = false
+		})
+
+		if !probed {
+			return nil
+		}
+
+		for _, nick := range r.Nicks() {
+			if c.nickManager.IsPrimary(nick) {
+				return nil
+			}
+		}
+	case *MonOfflineReply:
+		var found bool
+
+		for _, nick := range r.Nicks() {
+			if c.nickManager.IsPrimary(nick) {
+				found = true
+			}
+		}
+
+		if !found {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	c.log.Info("primary nick is free, taking it back", logger.Param{Key: "nick", Value: primary})
+
+	return c.EnqueueCommand(NewNickCommand(primary))
+}
+
+func resetNicks(ctx context.Context, c *Connection) error {
+	c.nickManager.Reset()
+
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		conn.Status.Hostmask = ""
+		conn.regainProbe = false
+	})
+
+	return nil
 }
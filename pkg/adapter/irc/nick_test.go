@@ -0,0 +1,101 @@
+package irc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNickManager(t *testing.T) {
+	nm := NewNickManager([]string{"tenyks", "tenyks-bot"})
+
+	require.Equal(t, "tenyks", nm.Next())
+
+	nm.Reject("tenyks")
+	require.Equal(t, "tenyks-bot", nm.Next())
+
+	nm.Reject("tenyks-bot")
+	require.Equal(t, "tenyks_", nm.Next())
+
+	nm.Reject("tenyks_")
+	require.Equal(t, "tenyks__", nm.Next())
+
+	nm.Reject("tenyks__")
+	require.Equal(t, "tenyks1", nm.Next())
+
+	nm.Confirm("tenyks1")
+	require.Equal(t, "tenyks1", nm.Current())
+	require.False(t, nm.HasPrimary())
+
+	nm.Confirm("tenyks")
+	require.True(t, nm.HasPrimary())
+
+	nm.Reset()
+	require.Equal(t, "tenyks", nm.Next())
+	require.Equal(t, "", nm.Current())
+}
+
+func TestNickCollisionDuringRegistration(t *testing.T) {
+	ctx := context.Background()
+	c := newTestConnection(t, Config{User: "tenyks", RealName: "tenyks", Nicks: []string{"tenyks", "tenyks-bot"}})
+
+	require.NoError(t, defaultLoginFunc(ctx, c))
+	require.Contains(t, drainCommands(t, c), "NICK tenyks\r\n")
+
+	for _, raw := range []string{
+		":irc.example.com 433 * tenyks :Nickname is already in use",
+		":irc.example.com 437 * tenyks-bot :Nick/channel is temporarily unavailable",
+	} {
+		require.NoError(t, defaultNickCollisionHandler(ctx, c, mustDecode(t, c, raw).(Reply)))
+	}
+
+	require.Equal(t, []string{"NICK tenyks-bot\r\n", "NICK tenyks_\r\n"}, drainCommands(t, c))
+
+	welcome := mustDecode(t, c, ":irc.example.com 001 tenyks_ :Welcome")
+	require.NoError(t, defaultConnectionStatusUpdater(ctx, c, welcome.(Reply)))
+	require.Equal(t, "tenyks_", c.Status.CurrentNick)
+
+	// once registered, a rejected nick doesn't make us pick another one
+	inUse := mustDecode(t, c, ":irc.example.com 433 tenyks_ tenyks :Nickname is already in use")
+	require.NoError(t, defaultNickCollisionHandler(ctx, c, inUse.(Reply)))
+	require.Empty(t, drainCommands(t, c))
+}
+
+func TestNickRegain(t *testing.T) {
+	ctx := context.Background()
+	c := newTestConnection(t, Config{Nicks: []string{"tenyks"}})
+
+	welcome := mustDecode(t, c, ":irc.example.com 001 tenyks_ :Welcome")
+	require.NoError(t, defaultConnectionStatusUpdater(ctx, c, welcome.(Reply)))
+
+	// an ISON we didn't send isn't about the primary nick
+	offline := mustDecode(t, c, ":irc.example.com 303 tenyks_ :")
+	require.NoError(t, defaultNickRegainHandler(ctx, c, offline.(Reply)))
+	require.Empty(t, drainCommands(t, c))
+
+	require.NoError(t, c.sendRegainProbe(ctx, "tenyks"))
+	require.Equal(t, []string{"ISON tenyks\r\n"}, drainCommands(t, c))
+
+	online := mustDecode(t, c, ":irc.example.com 303 tenyks_ :tenyks")
+	require.NoError(t, defaultNickRegainHandler(ctx, c, online.(Reply)))
+	require.Empty(t, drainCommands(t, c))
+
+	require.NoError(t, c.sendRegainProbe(ctx, "tenyks"))
+	drainCommands(t, c)
+
+	require.NoError(t, defaultNickRegainHandler(ctx, c, offline.(Reply)))
+	require.Equal(t, []string{"NICK tenyks\r\n"}, drainCommands(t, c))
+
+	monOffline := mustDecode(t, c, ":irc.example.com 731 tenyks_ :tenyks")
+	require.NoError(t, defaultNickRegainHandler(ctx, c, monOffline.(Reply)))
+	require.Equal(t, []string{"NICK tenyks\r\n"}, drainCommands(t, c))
+
+	// CurrentNick only changes when the server confirms it
+	require.Equal(t, "tenyks_", c.Status.CurrentNick)
+
+	nick := mustDecode(t, c, ":tenyks_!tenyks@example.com NICK :tenyks")
+	require.NoError(t, defaultNickChangeHandler(ctx, c, nick.(Command)))
+	require.Equal(t, "tenyks", c.Status.CurrentNick)
+	require.True(t, c.nickManager.HasPrimary())
+}
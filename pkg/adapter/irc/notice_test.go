@@ -0,0 +1,49 @@
+package irc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kyleterry/tenyks/pkg/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNoticeForwarding(t *testing.T) {
+	c := newTestConnection(t, Config{})
+	c.Status.CurrentNick = "tenyks"
+
+	msgs := []*message.ChatMessage{}
+	c.RegisterMessageHandler(func(msg message.Message) {
+		msgs = append(msgs, msg.(*message.ChatMessage))
+	})
+
+	require.IsType(t, &NoticeCommand{}, mustDecode(t, c, ":NickServ!NickServ@services. NOTICE tenyks :You are now identified"))
+
+	dispatch(t, c, ":irc.test NOTICE * :*** Looking up your hostname...")
+	dispatch(t, c, ":NickServ!NickServ@services. NOTICE tenyks :You are now identified")
+	dispatch(t, c, ":alice!~alice@example.com NOTICE tenyks :\x01VERSION irssi\x01")
+
+	require.Len(t, msgs, 1)
+	require.True(t, msgs[0].Notice)
+	require.Equal(t, "You are now identified", msgs[0].Content)
+
+	// notices never get a reply from us
+	require.Empty(t, drainCommands(t, c))
+}
+
+func TestSendNotice(t *testing.T) {
+	decoder := tenyksChatMessageDecoder{budget: func(string) int { return 100 }}
+
+	cmds, err := decoder.Decode(&message.ChatMessage{
+		DestinationPath: "/irc/test/otherbot",
+		Content:         "no results",
+		Notice:          true,
+		Timestamp:       time.Now(),
+	})
+	require.NoError(t, err)
+	require.Len(t, cmds, 1)
+
+	line, err := cmds[0].Encode()
+	require.NoError(t, err)
+	require.Equal(t, "NOTICE otherbot :no results\r\n", line)
+}
@@ -0,0 +1,151 @@
+package irc
+
+import (
+	"context"
+	"time"
+
+	"github.com/kyleterry/tenyks/pkg/logger"
+)
+
+const (
+	// DefaultRejoinPolicy is used for channels without a policy of their
+	// own.
+	DefaultRejoinPolicy = RejoinPolicyDelay
+	// DefaultRejoinDelay is how long we wait before the first attempt to get
+	// back into a channel. The wait doubles after every failure.
+	DefaultRejoinDelay = time.Second * 10
+	// DefaultRejoinMaxDelay caps the wait between rejoin attempts.
+	DefaultRejoinMaxDelay = time.Minute * 10
+)
+
+// channelFailed moves a channel into the error state with reason as its
+// message and schedules a rejoin if the channel's policy allows one.
+func (c *Connection) channelFailed(ctx context.Context, name, reason string) {
+	var (
+		delay   time.Duration
+		waiting bool
+	)
+
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		channel, ok := conn.channel(name)
+		if !ok {
+			return
+		}
+
+		channel.Status.Status = ChannelStatusErr
+		channel.Status.Message = reason
+		channel.Status.reset()
+
+		switch {
+		case channel.RejoinPolicy == RejoinPolicyNever, channel.rejoinPending:
+		case channel.RejoinPolicy == RejoinPolicyAfterSASL && conn.Status.Account == "":
+			waiting = true
+		default:
+			channel.rejoinPending = true
+			delay = channel.rejoin.next()
+		}
+	})
+
+	switch {
+	case waiting:
+		c.log.Info("waiting for login to rejoin channel", logger.Param{Key: "channel", Value: name})
+	case delay > 0:
+		c.scheduleRejoin(name, delay)
+	}
+}
+
+// scheduleRejoin sends a JOIN for a channel after delay, unless we got back
+// in some other way or the session ended first.
+func (c *Connection) scheduleRejoin(name string, delay time.Duration) {
+	sctx := c.sessionContext()
+
+	c.log.Info("rejoining channel",
+		logger.Param{Key: "channel", Value: name},
+		logger.Param{Key: "delay", Value: delay})
+
+	go func() {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-sctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		var join *Channel
+
+		c.WithWriteLock(sctx, func(conn *Connection) {
+			channel, ok := conn.channel(name)
+			if !ok || !channel.rejoinPending {
+				return
+			}
+
+			channel.rejoinPending = false
+
+			if channel.Status.Status == ChannelStatusErr {
+				join = channel
+			}
+		})
+
+		if join == nil {
+			return
+		}
+
+		if err := c.EnqueueCommand(c.joinCommands([]*Channel{join})[0]); err != nil {
+			c.log.Error("failed to rejoin channel",
+				logger.Param{Key: "channel", Value: name},
+				logger.Param{Key: "error", Value: err})
+		}
+	}()
+}
+
+// defaultJoinFailureHandler puts a channel into the error state when the
+// server won't let us join it because it's full, invite only, we're banned,
+// the key is wrong, it needs a registered nick, the name isn't valid or
+// we're in too many channels already.
+func defaultJoinFailureHandler(ctx context.Context, c *Connection, reply Reply) error {
+	r, ok := reply.(*ErrJoinFailedReply)
+	if !ok {
+		return nil
+	}
+
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	c.log.Info("failed to join channel",
+		logger.Param{Key: "channel", Value: r.Channel()},
+		logger.Param{Key: "reason", Value: r.Reason()})
+
+	c.channelFailed(ctx, r.Channel(), r.Reason())
+
+	return nil
+}
+
+// defaultRejoinAfterLoginHandler rejoins channels with the after-sasl policy
+// that were waiting for us to log into an account.
+func defaultRejoinAfterLoginHandler(ctx context.Context, c *Connection, reply Reply) error {
+	if _, ok := reply.(*LoggedInReply); !ok {
+		return nil
+	}
+
+	delays := map[string]time.Duration{}
+
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		for _, channel := range conn.channels {
+			if channel.RejoinPolicy != RejoinPolicyAfterSASL || channel.Status.Status != ChannelStatusErr || channel.rejoinPending {
+				continue
+			}
+
+			channel.rejoinPending = true
+			delays[channel.Name] = channel.rejoin.next()
+		}
+	})
+
+	for name, delay := range delays {
+		c.scheduleRejoin(name, delay)
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// waitForCommands drains the queues until n commands have arrived or a
+// second has passed.
+func waitForCommands(t *testing.T, c *Connection, n int) []string {
+	t.Helper()
+
+	lines := []string{}
+	deadline := time.Now().Add(time.Second)
+
+	for len(lines) < n && time.Now().Before(deadline) {
+		lines = append(lines, drainCommands(t, c)...)
+		time.Sleep(time.Millisecond * 5)
+	}
+
+	return lines
+}
+
+func TestRejoinPolicies(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := newTestConnection(t, Config{
+		Channels: []string{"#delay", "#never", "#registered"},
+		ChannelRejoinPolicies: map[string]RejoinPolicy{
+			"#Never":      RejoinPolicyNever,
+			"#registered": RejoinPolicyAfterSASL,
+		},
+		RejoinDelay:    time.Millisecond * 10,
+		RejoinMaxDelay: time.Millisecond * 20,
+	})
+	c.Status.CurrentNick = "tenyks"
+	c.session = newSession(ctx, nil)
+
+	for _, name := range []string{"#delay", "#never", "#registered"} {
+		dispatch(t, c, ":tenyks!~tenyks@example.com JOIN "+name)
+	}
+
+	drainCommands(t, c)
+
+	dispatch(t, c, ":alice!~alice@example.com KICK #delay tenyks :behave")
+	dispatch(t, c, ":alice!~alice@example.com KICK #never tenyks :behave")
+	dispatch(t, c, ":irc.test 477 tenyks #registered :Cannot join channel (+r) - you need to be identified with services")
+
+	c.RLock()
+	require.Equal(t, ChannelStatusErr, c.channels["#delay"].Status.Status)
+	require.Equal(t, "kicked by alice: behave", c.channels["#delay"].Status.Message)
+	require.Equal(t, ChannelStatusErr, c.channels["#never"].Status.Status)
+	require.Equal(t, ChannelStatusErr, c.channels["#registered"].Status.Status)
+	require.Equal(t, "Cannot join channel (+r) - you need to be identified with services", c.channels["#registered"].Status.Message)
+	c.RUnlock()
+
+	require.Equal(t, []string{"JOIN #delay\r\n"}, waitForCommands(t, c, 1))
+
+	// still banned, so the next attempt waits longer
+	dispatch(t, c, ":irc.test 474 tenyks #delay :Cannot join channel (+b)")
+
+	c.RLock()
+	require.EqualValues(t, 2, c.channels["#delay"].rejoin.n)
+	c.RUnlock()
+
+	require.Equal(t, []string{"JOIN #delay\r\n"}, waitForCommands(t, c, 1))
+
+	dispatch(t, c, ":irc.test 900 tenyks tenyks!~tenyks@example.com tenyks :You are now logged in as tenyks")
+
+	require.Equal(t, []string{"JOIN #registered\r\n"}, waitForCommands(t, c, 1))
+
+	// #never stays put
+	time.Sleep(time.Millisecond * 30)
+	require.Empty(t, drainCommands(t, c))
+
+	dispatch(t, c, ":tenyks!~tenyks@example.com JOIN #delay")
+
+	c.RLock()
+	require.Equal(t, ChannelStatusJoined, c.channels["#delay"].Status.Status)
+	require.Zero(t, c.channels["#delay"].rejoin.n)
+	c.RUnlock()
+}
+
+func TestInvalidRejoinPolicy(t *testing.T) {
+	_, err := New(Config{
+		Server:       "irc.example.com:6667",
+		Nicks:        []string{"tenyks"},
+		Channels:     []string{"#tenyks"},
+		RejoinPolicy: "sometimes",
+	})
+
+	require.True(t, errors.Is(err, ErrInvalidRejoinPolicy))
+}
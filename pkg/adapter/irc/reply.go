@@ -2,7 +2,9 @@ package irc
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Reply interface {
@@ -14,15 +16,144 @@ var DefaultReplyFactory = map[ReplyType]func(*Message) Reply{
 	ReplyTypeWelcome: func(msg *Message) Reply {
 		return &WelcomeReply{m: msg}
 	},
+	ReplyTypeISupport: func(msg *Message) Reply {
+		return &ISupportReply{m: msg}
+	},
+	ReplyTypeEndOfMOTD: func(msg *Message) Reply {
+		return &EndOfMOTDReply{m: msg}
+	},
+	ReplyTypeErrNoMOTD: func(msg *Message) Reply {
+		return &ErrNoMOTDReply{m: msg}
+	},
+	ReplyTypeNoTopic: func(msg *Message) Reply {
+		return &NoTopicReply{m: msg}
+	},
+	ReplyTypeTopic: func(msg *Message) Reply {
+		return &TopicReply{m: msg}
+	},
+	ReplyTypeTopicWhoTime: func(msg *Message) Reply {
+		return &TopicWhoTimeReply{m: msg}
+	},
 	ReplyTypeNames: func(msg *Message) Reply {
 		return &NamesReply{m: msg}
 	},
 	ReplyTypeEndOfNames: func(msg *Message) Reply {
 		return &EndOfNamesReply{m: msg}
 	},
+	ReplyTypeErrErroneusNickname: func(msg *Message) Reply {
+		return &ErrErroneusNicknameReply{m: msg}
+	},
 	ReplyTypeErrNickInUse: func(msg *Message) Reply {
 		return &ErrNickInUseReply{m: msg}
 	},
+	ReplyTypeErrNickCollision: func(msg *Message) Reply {
+		return &ErrNickCollisionReply{m: msg}
+	},
+	ReplyTypeErrUnavailResource: func(msg *Message) Reply {
+		return &ErrUnavailResourceReply{m: msg}
+	},
+	ReplyTypeErrChannelIsFull: func(msg *Message) Reply {
+		return &ErrJoinFailedReply{m: msg}
+	},
+	ReplyTypeErrInviteOnlyChan: func(msg *Message) Reply {
+		return &ErrJoinFailedReply{m: msg}
+	},
+	ReplyTypeErrBannedFromChan: func(msg *Message) Reply {
+		return &ErrJoinFailedReply{m: msg}
+	},
+	ReplyTypeErrBadChannelKey: func(msg *Message) Reply {
+		return &ErrJoinFailedReply{m: msg}
+	},
+	ReplyTypeErrNeedReggedNick: func(msg *Message) Reply {
+		return &ErrJoinFailedReply{m: msg}
+	},
+	ReplyTypeErrNoSuchChannel: func(msg *Message) Reply {
+		return &ErrJoinFailedReply{m: msg}
+	},
+	ReplyTypeErrTooManyChannels: func(msg *Message) Reply {
+		return &ErrJoinFailedReply{m: msg}
+	},
+	ReplyTypeErrBadChanMask: func(msg *Message) Reply {
+		return &ErrJoinFailedReply{m: msg}
+	},
+	ReplyTypeWho: func(msg *Message) Reply {
+		return &WhoReply{m: msg}
+	},
+	ReplyTypeWhox: func(msg *Message) Reply {
+		return &WhoxReply{m: msg}
+	},
+	ReplyTypeEndOfWho: func(msg *Message) Reply {
+		return &EndOfWhoReply{m: msg}
+	},
+	ReplyTypeWhoisUser: func(msg *Message) Reply {
+		return &WhoisUserReply{m: msg}
+	},
+	ReplyTypeWhoisChannels: func(msg *Message) Reply {
+		return &WhoisChannelsReply{m: msg}
+	},
+	ReplyTypeWhoisAccount: func(msg *Message) Reply {
+		return &WhoisAccountReply{m: msg}
+	},
+	ReplyTypeEndOfWhois: func(msg *Message) Reply {
+		return &EndOfWhoisReply{m: msg}
+	},
+	ReplyTypeErrNoSuchNick: func(msg *Message) Reply {
+		return &ErrNoSuchNickReply{m: msg}
+	},
+	ReplyTypeErrCannotSendToChan: func(msg *Message) Reply {
+		return &ErrCannotSendToChanReply{m: msg}
+	},
+	ReplyTypeIson: func(msg *Message) Reply {
+		return &IsonReply{m: msg}
+	},
+	ReplyTypeMonOnline: func(msg *Message) Reply {
+		return &MonOnlineReply{m: msg}
+	},
+	ReplyTypeMonOffline: func(msg *Message) Reply {
+		return &MonOfflineReply{m: msg}
+	},
+	ReplyTypeLoggedIn: func(msg *Message) Reply {
+		return &LoggedInReply{m: msg}
+	},
+	ReplyTypeErrNickLocked: func(msg *Message) Reply {
+		return &ErrNickLockedReply{m: msg}
+	},
+	ReplyTypeSASLSuccess: func(msg *Message) Reply {
+		return &SASLSuccessReply{m: msg}
+	},
+	ReplyTypeErrSASLFail: func(msg *Message) Reply {
+		return &ErrSASLFailReply{m: msg}
+	},
+	ReplyTypeErrSASLTooLong: func(msg *Message) Reply {
+		return &ErrSASLTooLongReply{m: msg}
+	},
+	ReplyTypeErrSASLAborted: func(msg *Message) Reply {
+		return &ErrSASLAbortedReply{m: msg}
+	},
+	ReplyTypeErrSASLAlready: func(msg *Message) Reply {
+		return &ErrSASLAlreadyReply{m: msg}
+	},
+	ReplyTypeChannelModeIs: func(msg *Message) Reply {
+		return &ChannelModeIsReply{m: msg}
+	},
+	ReplyTypeBanList: func(msg *Message) Reply {
+		return &ListModeReply{m: msg, mode: 'b'}
+	},
+	ReplyTypeEndOfBanList: func(msg *Message) Reply {
+		return &EndOfListModeReply{m: msg, mode: 'b'}
+	},
+	ReplyTypeExceptList: func(msg *Message) Reply {
+		return &ListModeReply{m: msg, mode: 'e'}
+	},
+	ReplyTypeEndOfExceptList: func(msg *Message) Reply {
+		return &EndOfListModeReply{m: msg, mode: 'e'}
+	},
+	ReplyTypeInviteList: func(msg *Message) Reply {
+		return &ListModeReply{m: msg, mode: 'I'}
+	},
+	ReplyTypeEndOfInviteList: func(msg *Message) Reply {
+		return &EndOfListModeReply{m: msg, mode: 'I'}
+	},
 }
 
 type WelcomeReply struct {
@@ -37,6 +168,157 @@ func (r WelcomeReply) Validate() error {
 	return nil
 }
 
+// Nick returns the nick the server registered us with.
+func (r WelcomeReply) Nick() string {
+	if len(r.m.Params) == 0 {
+		return ""
+	}
+
+	return r.m.Params[0]
+}
+
+// ISupportReply is RPL_ISUPPORT (005). It advertises the features and limits
+// of the server as a list of TOKEN or TOKEN=value parameters.
+type ISupportReply struct {
+	m *Message
+}
+
+func (r ISupportReply) Message() *Message {
+	return r.m
+}
+
+func (r ISupportReply) Validate() error {
+	if len(r.m.Params) < 2 {
+		return fmt.Errorf("%w: expected at least 2, but got %d", ParameterCountValidationError, len(r.m.Params))
+	}
+
+	return nil
+}
+
+// Tokens returns the feature tokens, skipping our nick.
+func (r ISupportReply) Tokens() []string {
+	return r.m.Params[1:]
+}
+
+// EndOfMOTDReply is RPL_ENDOFMOTD (376). The MOTD is the last thing the
+// server sends when we register, after RPL_ISUPPORT.
+type EndOfMOTDReply struct {
+	m *Message
+}
+
+func (r EndOfMOTDReply) Message() *Message {
+	return r.m
+}
+
+func (r EndOfMOTDReply) Validate() error {
+	return nil
+}
+
+// ErrNoMOTDReply is ERR_NOMOTD (422). It's sent instead of the MOTD when the
+// server doesn't have one.
+type ErrNoMOTDReply struct {
+	m *Message
+}
+
+func (r ErrNoMOTDReply) Message() *Message {
+	return r.m
+}
+
+func (r ErrNoMOTDReply) Validate() error {
+	return nil
+}
+
+// NoTopicReply is RPL_NOTOPIC (331). The channel doesn't have a topic.
+type NoTopicReply struct {
+	m *Message
+}
+
+func (r NoTopicReply) Message() *Message {
+	return r.m
+}
+
+func (r NoTopicReply) Validate() error {
+	if len(r.m.Params) < 2 {
+		return fmt.Errorf("%w: expected 2, but got %d", ParameterCountValidationError, len(r.m.Params))
+	}
+
+	return nil
+}
+
+func (r NoTopicReply) Channel() string {
+	return r.m.Params[1]
+}
+
+// TopicReply is RPL_TOPIC (332). It's sent when we join a channel and when we
+// ask for the topic.
+type TopicReply struct {
+	m *Message
+}
+
+func (r TopicReply) Message() *Message {
+	return r.m
+}
+
+func (r TopicReply) Validate() error {
+	if len(r.m.Params) < 2 {
+		return fmt.Errorf("%w: expected 2, but got %d", ParameterCountValidationError, len(r.m.Params))
+	}
+
+	return nil
+}
+
+func (r TopicReply) Channel() string {
+	return r.m.Params[1]
+}
+
+func (r TopicReply) Topic() string {
+	return r.m.Trail
+}
+
+// TopicWhoTimeReply is RPL_TOPICWHOTIME (333). It follows RPL_TOPIC and says
+// who set the topic and when.
+type TopicWhoTimeReply struct {
+	m *Message
+}
+
+func (r TopicWhoTimeReply) Message() *Message {
+	return r.m
+}
+
+func (r TopicWhoTimeReply) Validate() error {
+	if len(r.m.Params) < 3 {
+		return fmt.Errorf("%w: expected at least 3, but got %d", ParameterCountValidationError, len(r.m.Params))
+	}
+
+	return nil
+}
+
+func (r TopicWhoTimeReply) Channel() string {
+	return r.m.Params[1]
+}
+
+// SetBy returns who set the topic. Depending on the server it's a nick or a
+// full nick!user@host.
+func (r TopicWhoTimeReply) SetBy() string {
+	return r.m.Params[2]
+}
+
+// SetAt returns when the topic was set, or the zero time if the server didn't
+// say.
+func (r TopicWhoTimeReply) SetAt() time.Time {
+	setAt := r.m.Trail
+	if len(r.m.Params) > 3 {
+		setAt = r.m.Params[3]
+	}
+
+	secs, err := strconv.ParseInt(setAt, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return time.Unix(secs, 0)
+}
+
 type NamesReply struct {
 	m *Message
 }
@@ -84,6 +366,74 @@ func (r EndOfNamesReply) Channel() string {
 	return r.m.Params[len(r.m.Params)-1]
 }
 
+// nickParam returns the nick an ERR_* nick reply is about. These replies
+// look like `<client> <nick> :<reason>`.
+func nickParam(m *Message) string {
+	if len(m.Params) < 2 {
+		return ""
+	}
+
+	return m.Params[1]
+}
+
+// ErrNoSuchNickReply is ERR_NOSUCHNICK (401). The nick or channel we sent
+// something to doesn't exist.
+type ErrNoSuchNickReply struct {
+	m *Message
+}
+
+func (r ErrNoSuchNickReply) Message() *Message {
+	return r.m
+}
+
+func (r ErrNoSuchNickReply) Validate() error {
+	return nil
+}
+
+func (r ErrNoSuchNickReply) Nick() string {
+	return nickParam(r.m)
+}
+
+// ErrCannotSendToChanReply is ERR_CANNOTSENDTOCHAN (404). The channel
+// wouldn't take our message, usually because it's moderated or we're banned.
+type ErrCannotSendToChanReply struct {
+	m *Message
+}
+
+func (r ErrCannotSendToChanReply) Message() *Message {
+	return r.m
+}
+
+func (r ErrCannotSendToChanReply) Validate() error {
+	if len(r.m.Params) < 2 {
+		return fmt.Errorf("%w: expected at least 2, but got %d", ParameterCountValidationError, len(r.m.Params))
+	}
+
+	return nil
+}
+
+func (r ErrCannotSendToChanReply) Channel() string {
+	return r.m.Params[1]
+}
+
+// ErrErroneusNicknameReply is ERR_ERRONEUSNICKNAME (432). The nick we asked
+// for has characters the server doesn't allow.
+type ErrErroneusNicknameReply struct {
+	m *Message
+}
+
+func (r ErrErroneusNicknameReply) Message() *Message {
+	return r.m
+}
+
+func (r ErrErroneusNicknameReply) Validate() error {
+	return nil
+}
+
+func (r ErrErroneusNicknameReply) Nick() string {
+	return nickParam(r.m)
+}
+
 type ErrNickInUseReply struct {
 	m *Message
 }
@@ -95,3 +445,560 @@ func (r ErrNickInUseReply) Message() *Message {
 func (r ErrNickInUseReply) Validate() error {
 	return nil
 }
+
+func (r ErrNickInUseReply) Nick() string {
+	return nickParam(r.m)
+}
+
+// ErrNickCollisionReply is ERR_NICKCOLLISION (436). The nick is in use on
+// another server.
+type ErrNickCollisionReply struct {
+	m *Message
+}
+
+func (r ErrNickCollisionReply) Message() *Message {
+	return r.m
+}
+
+func (r ErrNickCollisionReply) Validate() error {
+	return nil
+}
+
+func (r ErrNickCollisionReply) Nick() string {
+	return nickParam(r.m)
+}
+
+// ErrUnavailResourceReply is ERR_UNAVAILRESOURCE (437). The nick or channel
+// is temporarily unavailable, usually because of nick delay after a split.
+type ErrUnavailResourceReply struct {
+	m *Message
+}
+
+func (r ErrUnavailResourceReply) Message() *Message {
+	return r.m
+}
+
+func (r ErrUnavailResourceReply) Validate() error {
+	return nil
+}
+
+func (r ErrUnavailResourceReply) Nick() string {
+	return nickParam(r.m)
+}
+
+// ErrJoinFailedReply is one of the errors a server sends when it won't let us
+// into a channel: ERR_NOSUCHCHANNEL (403), ERR_TOOMANYCHANNELS (405),
+// ERR_CHANNELISFULL (471), ERR_INVITEONLYCHAN (473), ERR_BANNEDFROMCHAN
+// (474), ERR_BADCHANNELKEY (475), ERR_BADCHANMASK (476) or
+// ERR_NEEDREGGEDNICK (477).
+type ErrJoinFailedReply struct {
+	m *Message
+}
+
+func (r ErrJoinFailedReply) Message() *Message {
+	return r.m
+}
+
+func (r ErrJoinFailedReply) Validate() error {
+	if len(r.m.Params) < 2 {
+		return fmt.Errorf("%w: expected at least 2, but got %d", ParameterCountValidationError, len(r.m.Params))
+	}
+
+	return nil
+}
+
+func (r ErrJoinFailedReply) Channel() string {
+	return r.m.Params[1]
+}
+
+// Reason returns the server's explanation, like "Cannot join channel (+b)".
+func (r ErrJoinFailedReply) Reason() string {
+	if r.m.Trail != "" {
+		return r.m.Trail
+	}
+
+	return fmt.Sprintf("join failed with %s", r.m.Command)
+}
+
+// IsonReply is RPL_ISON (303). It lists the nicks from our ISON request that
+// are online.
+type IsonReply struct {
+	m *Message
+}
+
+func (r IsonReply) Message() *Message {
+	return r.m
+}
+
+func (r IsonReply) Validate() error {
+	return nil
+}
+
+func (r IsonReply) Nicks() []string {
+	return strings.Fields(r.m.Trail)
+}
+
+// MonOnlineReply is RPL_MONONLINE (730). It lists monitored nicks that are
+// online.
+type MonOnlineReply struct {
+	m *Message
+}
+
+func (r MonOnlineReply) Message() *Message {
+	return r.m
+}
+
+func (r MonOnlineReply) Validate() error {
+	return nil
+}
+
+// Nicks returns the nicks that came online. The server sends full hostmasks
+// here, so only the nick part is returned.
+func (r MonOnlineReply) Nicks() []string {
+	return monitorTargets(r.m.Trail)
+}
+
+// MonOfflineReply is RPL_MONOFFLINE (731). It lists monitored nicks that are
+// offline.
+type MonOfflineReply struct {
+	m *Message
+}
+
+func (r MonOfflineReply) Message() *Message {
+	return r.m
+}
+
+func (r MonOfflineReply) Validate() error {
+	return nil
+}
+
+func (r MonOfflineReply) Nicks() []string {
+	return monitorTargets(r.m.Trail)
+}
+
+func monitorTargets(trail string) []string {
+	nicks := []string{}
+
+	for _, target := range strings.Split(trail, ",") {
+		if i := strings.Index(target, "!"); i != -1 {
+			target = target[:i]
+		}
+
+		if target != "" {
+			nicks = append(nicks, target)
+		}
+	}
+
+	return nicks
+}
+
+// LoggedInReply is RPL_LOGGEDIN (900). It's sent when we're logged into an
+// account, usually as a result of SASL authentication.
+type LoggedInReply struct {
+	m *Message
+}
+
+func (r LoggedInReply) Message() *Message {
+	return r.m
+}
+
+func (r LoggedInReply) Validate() error {
+	if len(r.m.Params) != 3 {
+		return fmt.Errorf("%w: expected 3, but got %d", ParameterCountValidationError, len(r.m.Params))
+	}
+
+	return nil
+}
+
+// Account returns the name of the account we are logged in as.
+func (r LoggedInReply) Account() string {
+	return r.m.Params[2]
+}
+
+// ErrNickLockedReply is ERR_NICKLOCKED (902). It's sent instead of a SASL
+// result when the account can't be used with our nick.
+type ErrNickLockedReply struct {
+	m *Message
+}
+
+func (r ErrNickLockedReply) Message() *Message {
+	return r.m
+}
+
+func (r ErrNickLockedReply) Validate() error {
+	return nil
+}
+
+// SASLSuccessReply is RPL_SASLSUCCESS (903).
+type SASLSuccessReply struct {
+	m *Message
+}
+
+func (r SASLSuccessReply) Message() *Message {
+	return r.m
+}
+
+func (r SASLSuccessReply) Validate() error {
+	return nil
+}
+
+// ErrSASLFailReply is ERR_SASLFAIL (904). It's sent when authentication
+// fails because of bad credentials or an unsupported mechanism.
+type ErrSASLFailReply struct {
+	m *Message
+}
+
+func (r ErrSASLFailReply) Message() *Message {
+	return r.m
+}
+
+func (r ErrSASLFailReply) Validate() error {
+	return nil
+}
+
+// ErrSASLTooLongReply is ERR_SASLTOOLONG (905).
+type ErrSASLTooLongReply struct {
+	m *Message
+}
+
+func (r ErrSASLTooLongReply) Message() *Message {
+	return r.m
+}
+
+func (r ErrSASLTooLongReply) Validate() error {
+	return nil
+}
+
+// ErrSASLAbortedReply is ERR_SASLABORTED (906).
+type ErrSASLAbortedReply struct {
+	m *Message
+}
+
+func (r ErrSASLAbortedReply) Message() *Message {
+	return r.m
+}
+
+func (r ErrSASLAbortedReply) Validate() error {
+	return nil
+}
+
+// ErrSASLAlreadyReply is ERR_SASLALREADY (907). It's sent when we try to
+// authenticate after already having done so.
+type ErrSASLAlreadyReply struct {
+	m *Message
+}
+
+func (r ErrSASLAlreadyReply) Message() *Message {
+	return r.m
+}
+
+func (r ErrSASLAlreadyReply) Validate() error {
+	return nil
+}
+
+// ChannelModeIsReply is RPL_CHANNELMODEIS (324). It's the answer to a MODE
+// query and lists every mode set on the channel that isn't a list.
+type ChannelModeIsReply struct {
+	m *Message
+}
+
+func (r ChannelModeIsReply) Message() *Message {
+	return r.m
+}
+
+func (r ChannelModeIsReply) Validate() error {
+	// some servers, like InspIRCd, send the modes as the trail
+	if len(r.m.Params) == 2 && strings.TrimSpace(r.m.Trail) != "" {
+		return nil
+	}
+
+	if len(r.m.Params) < 3 {
+		return fmt.Errorf("%w: expected at least 3, but got %d", ParameterCountValidationError, len(r.m.Params))
+	}
+
+	return nil
+}
+
+func (r ChannelModeIsReply) Channel() string {
+	return r.m.Params[1]
+}
+
+// Modes parses the modes set on the channel. The trail may be the last
+// parameter or all of them.
+func (r ChannelModeIsReply) Modes(features *ServerFeatures) []ModeChange {
+	args := append([]string{}, r.m.Params[2:]...)
+	args = append(args, strings.Fields(r.m.Trail)...)
+
+	return features.ParseModes(args[0], args[1:])
+}
+
+// ListModeReply is one entry of a channel's ban (367), ban exception (348)
+// or invite exception (346) list.
+type ListModeReply struct {
+	m    *Message
+	mode byte
+}
+
+func (r ListModeReply) Message() *Message {
+	return r.m
+}
+
+func (r ListModeReply) Validate() error {
+	if len(r.m.Params) < 3 {
+		return fmt.Errorf("%w: expected at least 3, but got %d", ParameterCountValidationError, len(r.m.Params))
+	}
+
+	return nil
+}
+
+// Mode returns the list mode the entry belongs to: b, e or I.
+func (r ListModeReply) Mode() byte {
+	return r.mode
+}
+
+func (r ListModeReply) Channel() string {
+	return r.m.Params[1]
+}
+
+func (r ListModeReply) Mask() string {
+	return r.m.Params[2]
+}
+
+// EndOfListModeReply ends a ban (368), ban exception (349) or invite
+// exception (347) list.
+type EndOfListModeReply struct {
+	m    *Message
+	mode byte
+}
+
+func (r EndOfListModeReply) Message() *Message {
+	return r.m
+}
+
+func (r EndOfListModeReply) Validate() error {
+	if len(r.m.Params) < 2 {
+		return fmt.Errorf("%w: expected at least 2, but got %d", ParameterCountValidationError, len(r.m.Params))
+	}
+
+	return nil
+}
+
+func (r EndOfListModeReply) Mode() byte {
+	return r.mode
+}
+
+func (r EndOfListModeReply) Channel() string {
+	return r.m.Params[1]
+}
+
+// WhoReply is RPL_WHOREPLY (352). It describes one user matching a WHO.
+type WhoReply struct {
+	m *Message
+}
+
+func (r WhoReply) Message() *Message {
+	return r.m
+}
+
+func (r WhoReply) Validate() error {
+	if len(r.m.Params) < 7 {
+		return fmt.Errorf("%w: expected at least 7, but got %d", ParameterCountValidationError, len(r.m.Params))
+	}
+
+	return nil
+}
+
+// User returns what the reply tells us about the user. The trailing
+// parameter is the hop count followed by the real name.
+func (r WhoReply) User() WhoUser {
+	realName := r.m.Trail
+	if i := strings.IndexByte(realName, ' '); i != -1 {
+		realName = realName[i+1:]
+	} else {
+		realName = ""
+	}
+
+	return WhoUser{
+		Channel:  r.m.Params[1],
+		Ident:    r.m.Params[2],
+		Host:     r.m.Params[3],
+		Server:   r.m.Params[4],
+		Nick:     r.m.Params[5],
+		Flags:    r.m.Params[6],
+		RealName: realName,
+	}
+}
+
+// WhoxReply is RPL_WHOSPCRPL (354), the WHOX version of RPL_WHOREPLY. Which
+// parameters it has depends on the fields asked for, so only replies to our
+// own query, marked with whoxToken, can be read with User.
+type WhoxReply struct {
+	m *Message
+}
+
+func (r WhoxReply) Message() *Message {
+	return r.m
+}
+
+func (r WhoxReply) Validate() error {
+	if len(r.m.Params) < 2 {
+		return fmt.Errorf("%w: expected at least 2, but got %d", ParameterCountValidationError, len(r.m.Params))
+	}
+
+	return nil
+}
+
+func (r WhoxReply) Token() string {
+	return r.m.Params[1]
+}
+
+// User returns the user in a reply to a query using whoxFields.
+func (r WhoxReply) User() (WhoUser, bool) {
+	if r.Token() != whoxToken || len(r.m.Params) < 9 {
+		return WhoUser{}, false
+	}
+
+	account := r.m.Params[8]
+	if account == "0" {
+		account = ""
+	}
+
+	return WhoUser{
+		Channel:  r.m.Params[2],
+		Ident:    r.m.Params[3],
+		Host:     r.m.Params[4],
+		Server:   r.m.Params[5],
+		Nick:     r.m.Params[6],
+		Flags:    r.m.Params[7],
+		Account:  account,
+		RealName: r.m.Trail,
+	}, true
+}
+
+// EndOfWhoReply is RPL_ENDOFWHO (315).
+type EndOfWhoReply struct {
+	m *Message
+}
+
+func (r EndOfWhoReply) Message() *Message {
+	return r.m
+}
+
+func (r EndOfWhoReply) Validate() error {
+	if len(r.m.Params) < 2 {
+		return fmt.Errorf("%w: expected at least 2, but got %d", ParameterCountValidationError, len(r.m.Params))
+	}
+
+	return nil
+}
+
+func (r EndOfWhoReply) Mask() string {
+	return r.m.Params[1]
+}
+
+// WhoisUserReply is RPL_WHOISUSER (311). It starts the answer to a WHOIS
+// with the user's ident, host and real name.
+type WhoisUserReply struct {
+	m *Message
+}
+
+func (r WhoisUserReply) Message() *Message {
+	return r.m
+}
+
+func (r WhoisUserReply) Validate() error {
+	if len(r.m.Params) < 4 {
+		return fmt.Errorf("%w: expected at least 4, but got %d", ParameterCountValidationError, len(r.m.Params))
+	}
+
+	return nil
+}
+
+func (r WhoisUserReply) Nick() string {
+	return r.m.Params[1]
+}
+
+func (r WhoisUserReply) Ident() string {
+	return r.m.Params[2]
+}
+
+func (r WhoisUserReply) Host() string {
+	return r.m.Params[3]
+}
+
+func (r WhoisUserReply) RealName() string {
+	return r.m.Trail
+}
+
+// WhoisChannelsReply is RPL_WHOISCHANNELS (319). It lists the channels a
+// user is in, with their prefixes. Long lists span several replies.
+type WhoisChannelsReply struct {
+	m *Message
+}
+
+func (r WhoisChannelsReply) Message() *Message {
+	return r.m
+}
+
+func (r WhoisChannelsReply) Validate() error {
+	if len(r.m.Params) < 2 {
+		return fmt.Errorf("%w: expected at least 2, but got %d", ParameterCountValidationError, len(r.m.Params))
+	}
+
+	return nil
+}
+
+func (r WhoisChannelsReply) Nick() string {
+	return r.m.Params[1]
+}
+
+func (r WhoisChannelsReply) Channels() []string {
+	return strings.Fields(r.m.Trail)
+}
+
+// WhoisAccountReply is RPL_WHOISACCOUNT (330). The user is logged into a
+// services account.
+type WhoisAccountReply struct {
+	m *Message
+}
+
+func (r WhoisAccountReply) Message() *Message {
+	return r.m
+}
+
+func (r WhoisAccountReply) Validate() error {
+	if len(r.m.Params) < 3 {
+		return fmt.Errorf("%w: expected at least 3, but got %d", ParameterCountValidationError, len(r.m.Params))
+	}
+
+	return nil
+}
+
+func (r WhoisAccountReply) Nick() string {
+	return r.m.Params[1]
+}
+
+func (r WhoisAccountReply) Account() string {
+	return r.m.Params[2]
+}
+
+// EndOfWhoisReply is RPL_ENDOFWHOIS (318). It ends the answer to a WHOIS.
+type EndOfWhoisReply struct {
+	m *Message
+}
+
+func (r EndOfWhoisReply) Message() *Message {
+	return r.m
+}
+
+func (r EndOfWhoisReply) Validate() error {
+	if len(r.m.Params) < 2 {
+		return fmt.Errorf("%w: expected at least 2, but got %d", ParameterCountValidationError, len(r.m.Params))
+	}
+
+	return nil
+}
+
+func (r EndOfWhoisReply) Nick() string {
+	return r.m.Params[1]
+}
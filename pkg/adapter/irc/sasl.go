@@ -0,0 +1,259 @@
+package irc
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kyleterry/tenyks/pkg/logger"
+)
+
+const (
+	// SASLMechanismPlain authenticates with an account name and password.
+	SASLMechanismPlain = "PLAIN"
+	// SASLMechanismExternal authenticates with the TLS client certificate
+	// presented when connecting.
+	SASLMechanismExternal = "EXTERNAL"
+)
+
+// DefaultSASLTimeout is how long registration is held waiting for the server
+// to tell us how authentication went.
+const DefaultSASLTimeout = time.Second * 30
+
+// saslChunkSize is the largest base64 chunk an AUTHENTICATE command can
+// carry.
+const saslChunkSize = 400
+
+type saslConfig struct {
+	mechanism string
+	account   string
+	password  string
+	timeout   time.Duration
+	// authenticating is true from the moment we send the mechanism until the
+	// server tells us how it went.
+	authenticating bool
+}
+
+// payload returns the raw (not base64 encoded) response to the server's
+// empty challenge.
+func (s saslConfig) payload() []byte {
+	switch s.mechanism {
+	case SASLMechanismPlain:
+		return []byte(fmt.Sprintf("%s\x00%s\x00%s", s.account, s.account, s.password))
+	default:
+		// EXTERNAL uses the identity from the client certificate, so we send
+		// an empty response.
+		return nil
+	}
+}
+
+func validSASLMechanism(mechanism string) bool {
+	switch mechanism {
+	case SASLMechanismPlain, SASLMechanismExternal:
+		return true
+	}
+
+	return false
+}
+
+// saslAuthenticateChunks splits a payload into the AUTHENTICATE commands that
+// carry it. Payloads that end on a chunk boundary, including empty ones, are
+// terminated with "+".
+func saslAuthenticateChunks(payload []byte) []*AuthenticateCommand {
+	encoded := base64.StdEncoding.EncodeToString(payload)
+	cmds := []*AuthenticateCommand{}
+
+	for len(encoded) >= saslChunkSize {
+		cmds = append(cmds, NewAuthenticateCommand(encoded[:saslChunkSize]))
+		encoded = encoded[saslChunkSize:]
+	}
+
+	if encoded == "" {
+		encoded = "+"
+	}
+
+	return append(cmds, NewAuthenticateCommand(encoded))
+}
+
+// finishSASL marks authentication as done and reports if it was still in
+// progress, so a result that arrives after we gave up isn't handled twice.
+func (c *Connection) finishSASL(ctx context.Context) bool {
+	var authenticating bool
+
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		authenticating = conn.sasl.authenticating
+		conn.sasl.authenticating = false
+	})
+
+	return authenticating
+}
+
+func (c *Connection) saslFailed(ctx context.Context, err error) error {
+	if !c.finishSASL(ctx) {
+		return nil
+	}
+
+	c.dispatchError(ctx, err)
+
+	return c.ReleaseRegistration(ctx)
+}
+
+// watchSASLTimeout gives up on authentication if the server hasn't answered
+// within the SASL timeout, so a server that never does can't hold
+// registration forever.
+func (c *Connection) watchSASLTimeout() {
+	sctx := c.sessionContext()
+	timeout := c.sasl.timeout
+
+	go func() {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case <-sctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		var authenticating bool
+
+		c.WithReadLock(sctx, func(conn *Connection) {
+			authenticating = conn.sasl.authenticating
+		})
+
+		if !authenticating {
+			return
+		}
+
+		if err := c.EnqueueCommand(NewAuthenticateCommand("*")); err != nil {
+			c.log.Error("failed to abort SASL", logger.Param{Key: "error", Value: err})
+		}
+
+		if err := c.saslFailed(sctx, fmt.Errorf("%w: no response after %s", ErrSASLFailed, timeout)); err != nil {
+			c.log.Error("failed to end capability negotiation", logger.Param{Key: "error", Value: err})
+		}
+	}()
+}
+
+// defaultSASLCapabilityHook starts authentication once the server
+// acknowledges the sasl capability. Registration is held until the server
+// replies with success or failure, or the SASL timeout passes.
+func defaultSASLCapabilityHook(ctx context.Context, c *Connection, capability Capability, enabled bool) error {
+	if capability.Name != "sasl" || !enabled || c.sasl.mechanism == "" {
+		return nil
+	}
+
+	if capability.Value != "" {
+		var offered bool
+
+		for _, mechanism := range strings.Split(capability.Value, ",") {
+			if strings.EqualFold(mechanism, c.sasl.mechanism) {
+				offered = true
+			}
+		}
+
+		if !offered {
+			c.dispatchError(ctx, fmt.Errorf("%w: server offers %s", ErrSASLMechanismUnsupported, capability.Value))
+
+			return nil
+		}
+	}
+
+	c.HoldRegistration()
+
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		conn.sasl.authenticating = true
+	})
+
+	c.watchSASLTimeout()
+
+	return c.EnqueueCommand(NewAuthenticateCommand(c.sasl.mechanism))
+}
+
+// defaultSASLAuthenticateHandler answers the server's challenge with our
+// credentials.
+func defaultSASLAuthenticateHandler(ctx context.Context, c *Connection, command Command) error {
+	cmd, ok := command.(*AuthenticateCommand)
+	if !ok {
+		return nil
+	}
+
+	var authenticating bool
+
+	c.WithReadLock(ctx, func(conn *Connection) {
+		authenticating = conn.sasl.authenticating
+	})
+
+	if !authenticating {
+		return nil
+	}
+
+	// PLAIN and EXTERNAL only ever get an empty challenge
+	if cmd.Payload() != "+" {
+		if err := c.EnqueueCommand(NewAuthenticateCommand("*")); err != nil {
+			c.log.Error("failed to abort SASL", logger.Param{Key: "error", Value: err})
+		}
+
+		return c.saslFailed(ctx, fmt.Errorf("%w: unexpected challenge", ErrSASLFailed))
+	}
+
+	for _, chunk := range saslAuthenticateChunks(c.sasl.payload()) {
+		if err := c.EnqueueCommand(chunk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// defaultSASLReplyHandler finishes authentication when the server reports
+// how it went. Failures, including ERR_NICKLOCKED, are sent to the OnError
+// hooks and registration continues without an account.
+func defaultSASLReplyHandler(ctx context.Context, c *Connection, reply Reply) error {
+	switch r := reply.(type) {
+	case *LoggedInReply:
+		if err := r.Validate(); err != nil {
+			return err
+		}
+
+		c.WithWriteLock(ctx, func(conn *Connection) {
+			conn.Status.Account = r.Account()
+		})
+
+		c.log.Info("logged in", logger.Param{Key: "account", Value: r.Account()})
+	case *SASLSuccessReply, *ErrSASLAlreadyReply:
+		if !c.finishSASL(ctx) {
+			return nil
+		}
+
+		return c.ReleaseRegistration(ctx)
+	case *ErrNickLockedReply, *ErrSASLFailReply, *ErrSASLTooLongReply, *ErrSASLAbortedReply:
+		return c.saslFailed(ctx, fmt.Errorf("%w: %s", ErrSASLFailed, reply.Message().Trail))
+	}
+
+	return nil
+}
+
+// defaultSASLRegistrationCheck reports an error if SASL was configured but
+// the server never acknowledged the sasl capability, since registration
+// would otherwise complete without an account and nobody would know.
+func defaultSASLRegistrationCheck(ctx context.Context, c *Connection) error {
+	if c.sasl.mechanism == "" || c.HasCapability("sasl") {
+		return nil
+	}
+
+	c.dispatchError(ctx, fmt.Errorf("%w: server did not acknowledge the sasl capability", ErrSASLFailed))
+
+	return nil
+}
+
+func resetSASLStatus(ctx context.Context, c *Connection) error {
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		conn.sasl.authenticating = false
+		conn.Status.Account = ""
+	})
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSASLAuthenticateChunks(t *testing.T) {
+	chunks := saslAuthenticateChunks(nil)
+	require.Len(t, chunks, 1)
+	require.Equal(t, "+", chunks[0].Payload())
+
+	// 300 bytes encodes to exactly 400 base64 characters, so a "+" has to
+	// follow to tell the server the payload is done.
+	chunks = saslAuthenticateChunks([]byte(strings.Repeat("a", 300)))
+	require.Len(t, chunks, 2)
+	require.Len(t, chunks[0].Payload(), 400)
+	require.Equal(t, "+", chunks[1].Payload())
+
+	chunks = saslAuthenticateChunks([]byte(strings.Repeat("a", 301)))
+	require.Len(t, chunks, 2)
+	require.NotEqual(t, "+", chunks[1].Payload())
+}
+
+func TestSASLPlain(t *testing.T) {
+	ctx := context.Background()
+	c := newTestConnection(t, Config{
+		SASLMechanism: "plain",
+		SASLAccount:   "tenyks",
+		SASLPassword:  "hunter2",
+	})
+
+	require.NoError(t, defaultCapabilityFunc(ctx, c))
+
+	for _, raw := range []string{
+		":irc.example.com CAP * LS :sasl=PLAIN,EXTERNAL",
+		":irc.example.com CAP * ACK :sasl",
+		"AUTHENTICATE +",
+	} {
+		require.NoError(t, defaultCapabilityHandler(ctx, c, mustDecode(t, c, raw).(Command)))
+		require.NoError(t, defaultSASLAuthenticateHandler(ctx, c, mustDecode(t, c, raw).(Command)))
+	}
+
+	require.Equal(t, []string{
+		"CAP LS 302\r\n",
+		"CAP REQ :sasl\r\n",
+		"AUTHENTICATE PLAIN\r\n",
+		"AUTHENTICATE dGVueWtzAHRlbnlrcwBodW50ZXIy\r\n",
+	}, drainCommands(t, c))
+
+	for _, raw := range []string{
+		":irc.example.com 900 tenyks tenyks!tenyks@example.com tenyks :You are now logged in as tenyks",
+		":irc.example.com 903 tenyks :SASL authentication successful",
+	} {
+		require.NoError(t, defaultSASLReplyHandler(ctx, c, mustDecode(t, c, raw).(Reply)))
+	}
+
+	require.Equal(t, []string{"CAP END\r\n"}, drainCommands(t, c))
+	require.Equal(t, "tenyks", c.Status.Account)
+}
+
+func TestSASLFailure(t *testing.T) {
+	ctx := context.Background()
+	c := newTestConnection(t, Config{
+		SASLMechanism: SASLMechanismPlain,
+		SASLAccount:   "tenyks",
+		SASLPassword:  "wrong",
+	})
+
+	var errs []error
+	c.OnError = append(c.OnError, func(_ context.Context, _ *Connection, err error) error {
+		errs = append(errs, err)
+
+		return nil
+	})
+
+	require.NoError(t, defaultCapabilityFunc(ctx, c))
+
+	for _, raw := range []string{
+		":irc.example.com CAP * LS :sasl",
+		":irc.example.com CAP * ACK :sasl",
+	} {
+		require.NoError(t, defaultCapabilityHandler(ctx, c, mustDecode(t, c, raw).(Command)))
+	}
+
+	fail := mustDecode(t, c, ":irc.example.com 904 tenyks :SASL authentication failed")
+	require.NoError(t, defaultSASLReplyHandler(ctx, c, fail.(Reply)))
+
+	require.Len(t, errs, 1)
+	require.True(t, errors.Is(errs[0], ErrSASLFailed))
+	require.Equal(t, []string{
+		"CAP LS 302\r\n",
+		"CAP REQ :sasl\r\n",
+		"AUTHENTICATE PLAIN\r\n",
+		"CAP END\r\n",
+	}, drainCommands(t, c))
+}
+
+func TestSASLNickLocked(t *testing.T) {
+	ctx := context.Background()
+	c := newTestConnection(t, Config{
+		SASLMechanism: SASLMechanismPlain,
+		SASLAccount:   "tenyks",
+		SASLPassword:  "hunter2",
+	})
+
+	var errs []error
+	c.OnError = append(c.OnError, func(_ context.Context, _ *Connection, err error) error {
+		errs = append(errs, err)
+
+		return nil
+	})
+
+	require.NoError(t, defaultCapabilityFunc(ctx, c))
+
+	for _, raw := range []string{
+		":irc.example.com CAP * LS :sasl",
+		":irc.example.com CAP * ACK :sasl",
+	} {
+		require.NoError(t, defaultCapabilityHandler(ctx, c, mustDecode(t, c, raw).(Command)))
+	}
+
+	locked := mustDecode(t, c, ":irc.example.com 902 tenyks :You must use a nick assigned to you")
+	require.NoError(t, defaultSASLReplyHandler(ctx, c, locked.(Reply)))
+
+	// a late result doesn't end negotiation a second time
+	fail := mustDecode(t, c, ":irc.example.com 904 tenyks :SASL authentication failed")
+	require.NoError(t, defaultSASLReplyHandler(ctx, c, fail.(Reply)))
+
+	require.Len(t, errs, 1)
+	require.True(t, errors.Is(errs[0], ErrSASLFailed))
+	require.Equal(t, []string{
+		"CAP LS 302\r\n",
+		"CAP REQ :sasl\r\n",
+		"AUTHENTICATE PLAIN\r\n",
+		"CAP END\r\n",
+	}, drainCommands(t, c))
+}
+
+func TestSASLTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := newTestConnection(t, Config{
+		SASLMechanism: SASLMechanismPlain,
+		SASLAccount:   "tenyks",
+		SASLPassword:  "hunter2",
+		SASLTimeout:   time.Millisecond * 10,
+	})
+	c.session = newSession(ctx, nil)
+
+	errs := make(chan error, 1)
+	c.OnError = append(c.OnError, func(_ context.Context, _ *Connection, err error) error {
+		errs <- err
+
+		return nil
+	})
+
+	require.NoError(t, defaultCapabilityFunc(ctx, c))
+
+	for _, raw := range []string{
+		":irc.example.com CAP * LS :sasl",
+		":irc.example.com CAP * ACK :sasl",
+	} {
+		require.NoError(t, defaultCapabilityHandler(ctx, c, mustDecode(t, c, raw).(Command)))
+	}
+
+	select {
+	case err := <-errs:
+		require.True(t, errors.Is(err, ErrSASLFailed))
+	case <-time.After(time.Second):
+		t.Fatal("SASL never timed out")
+	}
+
+	lines := []string{}
+	require.Eventually(t, func() bool {
+		lines = append(lines, drainCommands(t, c)...)
+
+		return lines[len(lines)-1] == "CAP END\r\n"
+	}, time.Second, time.Millisecond*10)
+
+	require.Equal(t, []string{
+		"CAP LS 302\r\n",
+		"CAP REQ :sasl\r\n",
+		"AUTHENTICATE PLAIN\r\n",
+		"AUTHENTICATE *\r\n",
+		"CAP END\r\n",
+	}, lines)
+}
+
+func TestSASLInvalidMechanism(t *testing.T) {
+	_, err := New(Config{Server: "irc.example.com:6667", SASLMechanism: "SCRAM-SHA-256"})
+	require.True(t, errors.Is(err, ErrSASLMechanismUnsupported))
+}
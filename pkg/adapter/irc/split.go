@@ -0,0 +1,225 @@
+package irc
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxLineLength is the longest line, including CRLF, an IRC server
+	// accepts unless it advertises LINELEN. Message tags have a separate
+	// budget and don't count against it.
+	maxLineLength = 512
+	// maxIdentLength and maxHostLength are used to guess how long our prefix
+	// is before the server has shown it to us.
+	maxIdentLength = 10
+	maxHostLength  = 63
+	// maxPrefixLength is a generous guess at the length of a nick!user@host
+	// prefix when we don't know our nick.
+	maxPrefixLength = 30 + 1 + maxIdentLength + 1 + maxHostLength
+)
+
+// selfPrefix returns the prefix the server puts in front of our messages when
+// relaying them. If we haven't seen it yet, a prefix as long as the server is
+// likely to use is returned so we don't overestimate the space we have.
+func (c *Connection) selfPrefix() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	userLen, hostLen := maxIdentLength, maxHostLength
+
+	if c.features.UserLen > 0 {
+		userLen = c.features.UserLen
+	}
+
+	if c.features.HostLen > 0 {
+		hostLen = c.features.HostLen
+	}
+
+	nick := c.Status.CurrentNick
+	if nick == "" {
+		nick = c.nickManager.Primary()
+	}
+
+	if i := strings.Index(c.Status.Hostmask, "!"); i != -1 {
+		return nick + c.Status.Hostmask[i:]
+	}
+
+	// the server may add a ~ to the ident if it doesn't run identd
+	return nick + "!~" + strings.Repeat("x", userLen) + "@" + strings.Repeat("x", hostLen)
+}
+
+// privmsgBudget returns how many bytes of text fit in a PRIVMSG to target.
+func (c *Connection) privmsgBudget(target string) int {
+	return lineBudget(c.Features().LineLen, c.selfPrefix(), "PRIVMSG", target)
+}
+
+// lineBudget returns how many bytes are left for the trailing parameter of a
+// command once the server has relayed it as
+// ":prefix COMMAND target :text\r\n" in a line of at most lineLen bytes.
+func lineBudget(lineLen int, prefix, command, target string) int {
+	if lineLen <= 0 {
+		lineLen = maxLineLength
+	}
+
+	budget := lineLen - len(":"+prefix+" "+command+" "+target+" :\r\n")
+	if budget < 1 {
+		return 1
+	}
+
+	return budget
+}
+
+// splitMessage breaks content into lines that are at most max bytes long.
+// Embedded newlines always start a new line and empty lines are dropped since
+// servers reject empty messages. Long lines are broken at the last space
+// that fits, or mid-word if there isn't one, without splitting a UTF-8
+// character or a color code. A max below 1 is treated as 1, so a target
+// too long to leave room for content still makes progress.
+func splitMessage(content string, max int) []string {
+	if max < 1 {
+		max = 1
+	}
+
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.ReplaceAll(content, "\r", "\n")
+
+	lines := []string{}
+
+	for _, line := range strings.Split(content, "\n") {
+		for len(line) > max {
+			if i := strings.LastIndexByte(line[:max+1], ' '); i > 0 {
+				lines = append(lines, line[:i])
+				line = line[i+1:]
+
+				continue
+			}
+
+			i := safeCut(line, max)
+			lines = append(lines, line[:i])
+			line = line[i:]
+		}
+
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+// safeCut returns the largest index no greater than n where s can be cut
+// without splitting a UTF-8 character or a color code. If nothing fits, the
+// first character or code is returned whole so progress is always made.
+func safeCut(s string, n int) int {
+	if n >= len(s) {
+		return len(s)
+	}
+
+	i := n
+	for i > 0 && !utf8.RuneStart(s[i]) {
+		i--
+	}
+
+	// color codes are ascii, so the nearest one before i is the only one that
+	// could be cut in half
+	for j := i - 1; j >= 0 && j >= i-maxColorCodeLength; j-- {
+		if isColorCode(s[j]) {
+			if j+colorCodeLength(s[j:]) > i {
+				i = j
+			}
+
+			break
+		}
+	}
+
+	if i > 0 {
+		return i
+	}
+
+	if isColorCode(s[0]) {
+		return colorCodeLength(s)
+	}
+
+	_, size := utf8.DecodeRuneInString(s)
+
+	return size
+}
+
+const (
+	colorCode    = '\x03'
+	hexColorCode = '\x04'
+	// maxColorCodeLength is the length of \x04RRGGBB,RRGGBB
+	maxColorCodeLength = 14
+)
+
+func isColorCode(b byte) bool {
+	return b == colorCode || b == hexColorCode
+}
+
+// colorCodeLength returns the length of the color code at the start of s:
+// \x03 followed by up to two digit foreground and background colors, or \x04
+// followed by six digit hex colors.
+func colorCodeLength(s string) int {
+	digits, isDigit := 2, func(b byte) bool { return b >= '0' && b <= '9' }
+
+	if s[0] == hexColorCode {
+		digits, isDigit = 6, func(b byte) bool {
+			return (b >= '0' && b <= '9') || (b >= 'a' && b <= 'f') || (b >= 'A' && b <= 'F')
+		}
+	}
+
+	scan := func(i int) int {
+		for n := 0; n < digits && i < len(s) && isDigit(s[i]); n++ {
+			i++
+		}
+
+		return i
+	}
+
+	i := scan(1)
+
+	// a comma only belongs to the code if a background color follows it
+	if i > 1 && i+1 < len(s) && s[i] == ',' && isDigit(s[i+1]) {
+		i = scan(i + 1)
+	}
+
+	return i
+}
+
+// newPrivmsgCommands returns the PRIVMSGs needed to send content to target
+// with each message fitting in budget bytes.
+func newPrivmsgCommands(target, content string, budget int) []Command {
+	cmds := []Command{}
+
+	for _, line := range splitMessage(content, budget) {
+		cmds = append(cmds, NewPrivmsgCommand(target, line))
+	}
+
+	return cmds
+}
+
+// newActionCommands is like newPrivmsgCommands, but sends content as CTCP
+// ACTIONs. Every line is its own ACTION, so each one pays for the framing.
+func newActionCommands(target, content string, budget int) []Command {
+	cmds := []Command{}
+	framing := len(CTCPMessage{Command: CTCPAction, Params: " "}.String()) - 1
+
+	for _, line := range splitMessage(content, budget-framing) {
+		cmds = append(cmds, NewCTCPCommand(target, CTCPMessage{Command: CTCPAction, Params: line}))
+	}
+
+	return cmds
+}
+
+// newNoticeCommands is like newPrivmsgCommands, but sends content as
+// NOTICEs. NOTICE is shorter than PRIVMSG, so the PRIVMSG budget always fits.
+func newNoticeCommands(target, content string, budget int) []Command {
+	cmds := []Command{}
+
+	for _, line := range splitMessage(content, budget) {
+		cmds = append(cmds, NewNoticeCommand(target, line))
+	}
+
+	return cmds
+}
@@ -0,0 +1,122 @@
+package irc
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/kyleterry/tenyks/pkg/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		max      int
+		expected []string
+	}{
+		{
+			name:     "fits",
+			content:  "hello world",
+			max:      20,
+			expected: []string{"hello world"},
+		},
+		{
+			name:     "newlines",
+			content:  "one\r\ntwo\n\nthree\r",
+			max:      20,
+			expected: []string{"one", "two", "three"},
+		},
+		{
+			name:     "word boundary",
+			content:  "the quick brown fox",
+			max:      10,
+			expected: []string{"the quick", "brown fox"},
+		},
+		{
+			name:     "long word",
+			content:  "abcdefghij",
+			max:      4,
+			expected: []string{"abcd", "efgh", "ij"},
+		},
+		{
+			name:     "utf-8",
+			content:  "ééé",
+			max:      3,
+			expected: []string{"é", "é", "é"},
+		},
+		{
+			name:     "color code",
+			content:  "ab\x0304,12cd",
+			max:      7,
+			expected: []string{"ab", "\x0304,12c", "d"},
+		},
+		{
+			name:     "color code without background",
+			content:  "abc\x034,xyz",
+			max:      5,
+			expected: []string{"abc\x034", ",xyz"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lines := splitMessage(test.content, test.max)
+			require.Equal(t, test.expected, lines)
+
+			for _, line := range lines {
+				require.True(t, utf8.ValidString(line))
+			}
+		})
+	}
+}
+
+func TestPrivmsgBudget(t *testing.T) {
+	c := newTestConnection(t, Config{})
+
+	c.Status.CurrentNick = "tenyks"
+	c.Status.Hostmask = "tenyks!~tenyks@example.com"
+
+	budget := c.privmsgBudget("#tenyks")
+	require.Equal(t, 512-len(":tenyks!~tenyks@example.com PRIVMSG #tenyks :\r\n"), budget)
+
+	c.Status.Hostmask = ""
+	require.True(t, c.privmsgBudget("#tenyks") < budget)
+}
+
+func TestChatMessageDecoderSplits(t *testing.T) {
+	decoder := tenyksChatMessageDecoder{budget: func(string) int { return 100 }}
+
+	cmds, err := decoder.Decode(&message.ChatMessage{
+		DestinationPath: "/irc/#tenyks",
+		Content:         strings.Repeat("word ", 50) + "\nsecond line",
+	})
+	require.NoError(t, err)
+	require.Len(t, cmds, 4)
+
+	for _, cmd := range cmds {
+		require.NoError(t, cmd.Validate())
+		require.Equal(t, "#tenyks", cmd.Message().Params[0])
+		require.True(t, len(cmd.Message().Trail) <= 100)
+	}
+
+	require.Equal(t, "second line", cmds[3].Message().Trail)
+
+	_, err = decoder.Decode(&message.ChatMessage{DestinationPath: "/irc/#tenyks", Content: "\n"})
+	require.Error(t, err)
+}
+
+func TestActionWithLongTarget(t *testing.T) {
+	c := newTestConnection(t, Config{})
+	c.Status.CurrentNick = "tenyks"
+
+	target := "#" + strings.Repeat("a", 500)
+
+	cmds := newActionCommands(target, "waves", c.privmsgBudget(target))
+	require.NotEmpty(t, cmds)
+
+	for _, cmd := range cmds {
+		require.NoError(t, cmd.Validate())
+	}
+}
@@ -0,0 +1,268 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/kyleterry/tenyks/pkg/logger"
+)
+
+const (
+	reconnectMinBackoff = time.Second
+	reconnectMaxBackoff = time.Minute * 5
+)
+
+// session is a single socket connection to the server. The supervisor
+// creates a new session every time it dials and throws it away when the
+// connection is lost. Everything that has to survive a reconnect (send
+// queues, channels, hooks) lives on the Connection instead.
+type session struct {
+	conn   net.Conn
+	io     *bufio.ReadWriter
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// done is closed the first time end is called. err holds the reason.
+	done chan struct{}
+	err  error
+	once sync.Once
+
+	// registered is closed once registration is complete, at the end of
+	// the MOTD. The send loop holds back the normal queue until then.
+	registered     chan struct{}
+	registeredOnce sync.Once
+
+	wg sync.WaitGroup
+}
+
+func newSession(ctx context.Context, conn net.Conn) *session {
+	ctx, cancel := context.WithCancel(ctx)
+
+	return &session{
+		conn:       conn,
+		io:         bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn)),
+		ctx:        ctx,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+		registered: make(chan struct{}),
+	}
+}
+
+// end marks the session as disconnected. Only the first reason is kept.
+func (s *session) end(err error) {
+	s.once.Do(func() {
+		s.err = err
+		close(s.done)
+	})
+}
+
+func (s *session) markRegistered() {
+	s.registeredOnce.Do(func() {
+		close(s.registered)
+	})
+}
+
+// sessionContext returns a context that's canceled when the current session
+// ends. Hooks use it to bound goroutines to a single connection. If there's
+// no session the returned context is already canceled.
+func (c *Connection) sessionContext() context.Context {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.session != nil {
+		return c.session.ctx
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	return ctx
+}
+
+// disconnect ends the current session so the supervisor reconnects. It's
+// safe to call when there's no session.
+func (c *Connection) disconnect(err error) {
+	c.RLock()
+	s := c.session
+	c.RUnlock()
+
+	if s != nil {
+		s.end(err)
+	}
+}
+
+// connect dials the server, starts the io loops for the new session and runs
+// the OnConnect hooks. If dialing or a hook fails it retries with backoff
+// until it succeeds or ctx is canceled, so the only error it returns is
+// ctx's.
+func (c *Connection) connect(ctx context.Context) error {
+	for {
+		conn, err := c.dialContext(ctx)
+		if err == nil {
+			if err = c.startSession(ctx, conn); err == nil {
+				return nil
+			}
+
+			err = fmt.Errorf("connect hook failed: %w", err)
+			c.dispatchError(ctx, err)
+
+			if err := c.teardown(ctx); err != nil {
+				c.dispatchError(ctx, fmt.Errorf("disconnect hook failed: %w", err))
+			}
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		dur := c.backoff.next()
+		c.log.Error("connection failed",
+			logger.Param{Key: "connection", Value: c.Name},
+			logger.Param{Key: "error", Value: err},
+			logger.Param{Key: "retry", Value: dur})
+
+		select {
+		case <-time.After(dur):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (c *Connection) startSession(ctx context.Context, conn net.Conn) error {
+	s := newSession(ctx, conn)
+
+	var tlsStatus *TLSStatus
+
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		tlsStatus = newTLSStatus(tlsConn.ConnectionState(), c.tlsConfig)
+
+		c.log.Info("tls session established",
+			logger.Param{Key: "connection", Value: c.Name},
+			logger.Param{Key: "version", Value: tlsStatus.Version},
+			logger.Param{Key: "fingerprint", Value: tlsStatus.PeerCertFingerprint})
+	}
+
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		conn.session = s
+		conn.Status.CurrentServer = conn.server
+		conn.Status.TLS = tlsStatus
+	})
+
+	recvErrs := c.startReceiveLoop(s.ctx, s)
+	sendErrs := c.startSendLoop(s.ctx, s)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		c.monitorErrs(s.ctx, recvErrs, sendErrs)
+	}()
+
+	for _, hook := range c.OnConnect {
+		if err := hook(ctx, c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// teardown stops the io loops of the current session, closes the socket and
+// runs the OnDisconnect hooks. Commands still on the send queues are kept for
+// the next session.
+func (c *Connection) teardown(ctx context.Context) error {
+	var s *session
+
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		s = conn.session
+		conn.session = nil
+		conn.Status.Connected = false
+	})
+
+	if s == nil {
+		return nil
+	}
+
+	s.end(nil)
+	s.cancel()
+	s.conn.Close()
+	s.wg.Wait()
+
+	for _, hook := range c.OnDisconnect {
+		if err := hook(ctx, c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isRegistered returns true if the current session finished registering.
+func (c *Connection) isRegistered() bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.session == nil {
+		return false
+	}
+
+	select {
+	case <-c.session.registered:
+		return true
+	default:
+		return false
+	}
+}
+
+// markRegistered tells the current session's send loop that registration is
+// complete and resets the reconnect backoff.
+func (c *Connection) markRegistered(ctx context.Context) {
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		conn.backoff.reset()
+
+		if conn.session != nil {
+			conn.session.markRegistered()
+		}
+	})
+}
+
+// supervise waits for the current session to end and reconnects. It exits
+// when ctx is canceled, which happens when Close is called.
+func (c *Connection) supervise(ctx context.Context) {
+	for {
+		c.RLock()
+		s := c.session
+		c.RUnlock()
+
+		if s == nil {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.done:
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		c.log.Error("disconnected",
+			logger.Param{Key: "connection", Value: c.Name},
+			logger.Param{Key: "reason", Value: s.err})
+
+		if err := c.teardown(ctx); err != nil {
+			c.dispatchError(ctx, fmt.Errorf("disconnect hook failed: %w", err))
+		}
+
+		if err := c.connect(ctx); err != nil {
+			return
+		}
+	}
+}
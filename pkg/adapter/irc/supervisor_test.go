@@ -0,0 +1,239 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeServer is one accepted client connection on a test listener.
+type fakeServer struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func acceptFakeServer(t *testing.T, ln net.Listener) *fakeServer {
+	t.Helper()
+
+	conn, err := ln.Accept()
+	require.NoError(t, err)
+
+	return &fakeServer{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+// readUntil reads lines until one starts with prefix and returns every line
+// read, including the match.
+func (s *fakeServer) readUntil(prefix string) []string {
+	s.t.Helper()
+
+	lines := []string{}
+
+	require.NoError(s.t, s.conn.SetReadDeadline(time.Now().Add(time.Second*5)))
+
+	for {
+		line, err := s.r.ReadString('\n')
+		require.NoError(s.t, err)
+
+		line = strings.TrimSuffix(line, "\r\n")
+		lines = append(lines, line)
+
+		if strings.HasPrefix(line, prefix) {
+			return lines
+		}
+	}
+}
+
+func (s *fakeServer) send(line string) {
+	s.t.Helper()
+
+	_, err := s.conn.Write([]byte(line + "\r\n"))
+	require.NoError(s.t, err)
+}
+
+func TestReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	c := newTestConnection(t, Config{
+		Server:   ln.Addr().String(),
+		User:     "tenyks",
+		RealName: "tenyks",
+		Channels: []string{"#tenyks"},
+	})
+	c.backoff = backoff{min: time.Millisecond, max: time.Millisecond * 10}
+
+	accepted := make(chan *fakeServer)
+	go func() {
+		for i := 0; i < 2; i++ {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			accepted <- &fakeServer{t: t, conn: conn, r: bufio.NewReader(conn)}
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	require.NoError(t, c.Dial(ctx))
+	defer c.Close(ctx)
+
+	first := <-accepted
+	first.readUntil("NICK")
+	first.send(":irc.test 001 tenyks :Welcome")
+	first.send(":irc.test 376 tenyks :End of /MOTD command.")
+	first.readUntil("JOIN #tenyks")
+	first.send(":irc.test ERROR :Closing link")
+	first.conn.Close()
+
+	second := <-accepted
+	defer second.conn.Close()
+
+	require.NoError(t, c.EnqueueCommand(NewPrivmsgCommand("#tenyks", "queued while away")))
+
+	for _, line := range second.readUntil("NICK") {
+		require.False(t, strings.HasPrefix(line, "PRIVMSG"), "sent before registration: %s", line)
+	}
+
+	second.send(":irc.test 001 tenyks :Welcome back")
+	second.send(":irc.test 376 tenyks :End of /MOTD command.")
+
+	lines := second.readUntil("PRIVMSG")
+	require.Contains(t, lines, "JOIN #tenyks")
+	require.Equal(t, "PRIVMSG #tenyks :queued while away", lines[len(lines)-1])
+}
+
+// TestReconnectWithFullQueue checks that hooks answering our own JOIN don't
+// wait on a send queue that's full of messages held back by flood control.
+// The dispatcher has to be free to register the next session, or the queue
+// is never drained.
+func TestReconnectWithFullQueue(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	c := newTestConnection(t, Config{
+		Server:     ln.Addr().String(),
+		User:       "tenyks",
+		RealName:   "tenyks",
+		Channels:   []string{"#tenyks"},
+		FloodBurst: 5,
+		FloodRate:  time.Hour,
+	})
+	c.backoff = backoff{min: time.Millisecond, max: time.Millisecond * 10}
+
+	accepted := make(chan *fakeServer)
+	go func() {
+		for i := 0; i < 2; i++ {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			accepted <- &fakeServer{t: t, conn: conn, r: bufio.NewReader(conn)}
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	require.NoError(t, c.Dial(ctx))
+	defer c.Close(ctx)
+
+	first := <-accepted
+	first.readUntil("NICK")
+	first.send(":irc.test 001 tenyks :Welcome")
+	first.send(":irc.test 376 tenyks :End of /MOTD command.")
+	first.readUntil("JOIN #tenyks")
+
+	// once the burst is spent one message waits on flood control and the
+	// rest fill the queue.
+	for len(c.out) < cap(c.out) {
+		require.NoError(t, c.EnqueueCommand(NewPrivmsgCommand("#tenyks", "backlog")))
+	}
+
+	first.send(":tenyks!tenyks@tenyks.test JOIN #tenyks")
+
+	require.Eventually(t, func() bool {
+		var joined bool
+
+		c.WithReadLock(ctx, func(conn *Connection) {
+			channel, ok := conn.channel("#tenyks")
+			joined = ok && channel.Status.Status == ChannelStatusJoined
+		})
+
+		return joined
+	}, time.Second*5, time.Millisecond*10)
+
+	first.conn.Close()
+
+	second := <-accepted
+	defer second.conn.Close()
+
+	second.readUntil("NICK")
+	second.send(":irc.test 001 tenyks :Welcome back")
+	second.send(":irc.test 376 tenyks :End of /MOTD command.")
+
+	require.Eventually(t, c.isRegistered, time.Second*5, time.Millisecond*10)
+}
+
+func TestReconnectAfterConnectHookFails(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	c := newTestConnection(t, Config{
+		Server:   ln.Addr().String(),
+		User:     "tenyks",
+		RealName: "tenyks",
+	})
+	c.backoff = backoff{min: time.Millisecond, max: time.Millisecond * 10}
+
+	var attempts int
+	c.OnConnect = append(c.OnConnect, func(ctx context.Context, c *Connection) error {
+		attempts++
+
+		if attempts == 1 {
+			return errors.New("hook failed")
+		}
+
+		return nil
+	})
+
+	accepted := make(chan *fakeServer, 2)
+	go func() {
+		for i := 0; i < 2; i++ {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			accepted <- &fakeServer{t: t, conn: conn, r: bufio.NewReader(conn)}
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	require.NoError(t, c.Dial(ctx))
+	defer c.Close(ctx)
+
+	first := <-accepted
+	defer first.conn.Close()
+
+	second := <-accepted
+	defer second.conn.Close()
+
+	second.readUntil("NICK")
+	require.Equal(t, 2, attempts)
+}
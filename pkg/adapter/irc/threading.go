@@ -0,0 +1,125 @@
+package irc
+
+import "context"
+
+const (
+	// replyTag holds the msgid of the message a message replies to.
+	replyTag = "+draft/reply"
+	// reactTag holds a reaction, usually an emoji, to the message in
+	// replyTag.
+	reactTag = "+draft/react"
+	// maxRecentSenders is how many message ids we remember the sender of.
+	maxRecentSenders = 512
+)
+
+// senderCache remembers who sent recent messages so replies can fall back to
+// addressing them by nick when client tags can't be sent. The oldest ids are
+// forgotten first.
+type senderCache struct {
+	nicks map[string]string
+	order []string
+}
+
+func newSenderCache() *senderCache {
+	return &senderCache{nicks: map[string]string{}}
+}
+
+func (sc *senderCache) add(msgid, nick string) {
+	if _, ok := sc.nicks[msgid]; !ok {
+		if len(sc.order) >= maxRecentSenders {
+			delete(sc.nicks, sc.order[0])
+			sc.order = sc.order[1:]
+		}
+
+		sc.order = append(sc.order, msgid)
+	}
+
+	sc.nicks[msgid] = nick
+}
+
+func (sc *senderCache) nick(msgid string) string {
+	return sc.nicks[msgid]
+}
+
+// replySender returns the nick that sent the message with msgid, or an
+// empty string if we don't remember it.
+func (c *Connection) replySender(msgid string) string {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.senders.nick(msgid)
+}
+
+// addressed prefixes content with "nick: " the way people reply on IRC
+// without threading. Content is left alone if we don't know the nick.
+func addressed(nick, content string) string {
+	if nick == "" {
+		return content
+	}
+
+	if content == "" {
+		return nick + ":"
+	}
+
+	return nick + ": " + content
+}
+
+// defaultSenderRecorder remembers who sent each message with a msgid.
+func defaultSenderRecorder(ctx context.Context, c *Connection, command Command) error {
+	switch command.(type) {
+	case *PrivmsgCommand, *NoticeCommand, *TagmsgCommand:
+	default:
+		return nil
+	}
+
+	msg := command.Message()
+
+	msgid := messageID(msg)
+	nick := prefixNick(msg)
+
+	if msgid == "" || nick == "" {
+		return nil
+	}
+
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		conn.senders.add(msgid, nick)
+	})
+
+	return nil
+}
+
+// defaultReactionHandler forwards reactions other users send with TAGMSG to
+// services. TAGMSGs that don't react to a message, like typing
+// notifications, are ignored.
+func defaultReactionHandler(ctx context.Context, c *Connection, command Command) error {
+	cmd, ok := command.(*TagmsgCommand)
+	if !ok {
+		return nil
+	}
+
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+
+	msg := cmd.Message()
+
+	if reaction, _ := msg.Tag(reactTag); reaction == "" || replyTo(msg) == "" {
+		return nil
+	}
+
+	// with echo-message our own reactions come back to us
+	nick := prefixNick(msg)
+	if nick == "" || c.isSelf(nick) {
+		return nil
+	}
+
+	e := c.chatMessageEncoder()
+	tmsg, err := e.EncodeReaction(cmd)
+	if err != nil {
+		return err
+	}
+
+	c.dispatchMessage(tmsg)
+
+	return nil
+}
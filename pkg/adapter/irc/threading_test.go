@@ -0,0 +1,91 @@
+package irc
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kyleterry/tenyks/pkg/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplies(t *testing.T) {
+	ctx := context.Background()
+
+	c := newTestConnection(t, Config{})
+	c.Status.CurrentNick = "tenyks"
+
+	msgs := []*message.ChatMessage{}
+	c.RegisterMessageHandler(func(msg message.Message) {
+		msgs = append(msgs, msg.(*message.ChatMessage))
+	})
+
+	dispatch(t, c, "@msgid=abc :alice!~alice@example.com PRIVMSG #tenyks :lunch?")
+	dispatch(t, c, "@msgid=def;+draft/reply=abc :bob!~bob@example.com PRIVMSG #tenyks :sure")
+
+	require.Len(t, msgs, 2)
+	require.Empty(t, msgs[0].ReplyTo)
+	require.Equal(t, "abc", msgs[1].ReplyTo)
+
+	send := func(msg *message.ChatMessage) []string {
+		t.Helper()
+
+		msg.DestinationPath = "/irc/test/#tenyks"
+		msg.Timestamp = time.Now()
+
+		require.NoError(t, c.SendAsync(ctx, msg))
+
+		return drainCommands(t, c)
+	}
+
+	t.Run("without client tags", func(t *testing.T) {
+		require.Equal(t, []string{"PRIVMSG #tenyks :alice: me too\r\n"}, send(&message.ChatMessage{Content: "me too", ReplyTo: "abc"}))
+		require.Equal(t, []string{"PRIVMSG #tenyks :bob: 👍\r\n"}, send(&message.ChatMessage{Reaction: "👍", ReplyTo: "def"}))
+		require.Equal(t, []string{"PRIVMSG #tenyks :who?\r\n"}, send(&message.ChatMessage{Content: "who?", ReplyTo: "unknown"}))
+	})
+
+	c.Status.Capabilities["message-tags"] = ""
+
+	t.Run("with client tags", func(t *testing.T) {
+		require.Equal(t, []string{"@+draft/reply=abc PRIVMSG #tenyks :me too\r\n"}, send(&message.ChatMessage{Content: "me too", ReplyTo: "abc"}))
+		require.Equal(t, []string{"@+draft/react=👍;+draft/reply=def TAGMSG #tenyks\r\n"}, send(&message.ChatMessage{Reaction: "👍", ReplyTo: "def"}))
+	})
+
+	t.Run("reaction without a message", func(t *testing.T) {
+		require.Error(t, c.SendAsync(ctx, &message.ChatMessage{DestinationPath: "/irc/test/#tenyks", Reaction: "👍"}))
+	})
+}
+
+func TestInboundReactions(t *testing.T) {
+	c := newTestConnection(t, Config{})
+	c.Status.CurrentNick = "tenyks"
+
+	msgs := []*message.ChatMessage{}
+	c.RegisterMessageHandler(func(msg message.Message) {
+		msgs = append(msgs, msg.(*message.ChatMessage))
+	})
+
+	dispatch(t, c, "@msgid=ghi;+draft/react=🎉;+draft/reply=abc :alice!~alice@example.com TAGMSG #tenyks")
+	// typing notifications and our own echoed reactions aren't forwarded
+	dispatch(t, c, "@+typing=active :bob!~bob@example.com TAGMSG #tenyks")
+	dispatch(t, c, "@+draft/react=👍;+draft/reply=abc :tenyks!~tenyks@example.com TAGMSG #tenyks")
+
+	require.Len(t, msgs, 1)
+	require.Equal(t, "🎉", msgs[0].Reaction)
+	require.Equal(t, "abc", msgs[0].ReplyTo)
+	require.Equal(t, "ghi", msgs[0].MessageID)
+	require.Empty(t, msgs[0].Content)
+}
+
+func TestSenderCache(t *testing.T) {
+	sc := newSenderCache()
+
+	for i := 0; i < maxRecentSenders+1; i++ {
+		sc.add(fmt.Sprintf("id%d", i), "alice")
+	}
+
+	require.Empty(t, sc.nick("id0"))
+	require.Equal(t, "alice", sc.nick("id1"))
+	require.Len(t, sc.nicks, maxRecentSenders)
+}
@@ -0,0 +1,120 @@
+package irc
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+)
+
+// TLSStatus describes the TLS session of a connection.
+type TLSStatus struct {
+	// Version is the negotiated TLS version, such as "TLS 1.3".
+	Version string
+	// CipherSuite is the name of the negotiated cipher suite.
+	CipherSuite string
+	// ServerName is the name the server certificate was verified against.
+	ServerName string
+	// PeerCertFingerprint is the hex encoded SHA-256 fingerprint of the
+	// server's leaf certificate.
+	PeerCertFingerprint string
+	// ClientCertFingerprint is the hex encoded SHA-256 fingerprint of our
+	// client certificate. This is what services use for CertFP.
+	ClientCertFingerprint string
+}
+
+// newTLSConfig builds the tls.Config used to dial the server. It returns nil
+// if TLS isn't enabled.
+func newTLSConfig(conf Config) (*tls.Config, error) {
+	if !conf.UseTLS {
+		return nil, nil
+	}
+
+	host, _, err := net.SplitHostPort(conf.Server)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: conf.TLSInsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if conf.TLSServerName != "" {
+		tlsConfig.ServerName = conf.TLSServerName
+	}
+
+	if conf.RootCAPath != "" {
+		b, err := ioutil.ReadFile(conf.RootCAPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read root CA bundle: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("failed to parse root CA bundle %s: no certificates found", conf.RootCAPath)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if conf.ClientCertPath != "" || conf.ClientKeyPath != "" {
+		if conf.ClientCertPath == "" || conf.ClientKeyPath == "" {
+			return nil, errors.New("a client certificate requires both a certificate and key path")
+		}
+
+		cert, err := tls.LoadX509KeyPair(conf.ClientCertPath, conf.ClientKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// newTLSStatus builds a TLSStatus from the state of an established session.
+func newTLSStatus(state tls.ConnectionState, tlsConfig *tls.Config) *TLSStatus {
+	status := &TLSStatus{
+		Version:     tlsVersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		ServerName:  tlsConfig.ServerName,
+	}
+
+	if len(state.PeerCertificates) > 0 {
+		status.PeerCertFingerprint = certFingerprint(state.PeerCertificates[0].Raw)
+	}
+
+	if len(tlsConfig.Certificates) > 0 && len(tlsConfig.Certificates[0].Certificate) > 0 {
+		status.ClientCertFingerprint = certFingerprint(tlsConfig.Certificates[0].Certificate[0])
+	}
+
+	return status
+}
+
+func certFingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+
+	return hex.EncodeToString(sum[:])
+}
+
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+
+	return fmt.Sprintf("0x%04x", version)
+}
@@ -0,0 +1,128 @@
+package irc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestCertificate writes a self-signed certificate and key to dir and
+// returns their paths.
+func writeTestCertificate(t *testing.T, dir string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "irc.example.com"},
+		DNSNames:              []string{"irc.example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+
+	require.NoError(t, ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	return certPath, keyPath
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	certPath, keyPath := writeTestCertificate(t, t.TempDir())
+
+	tlsConfig, err := newTLSConfig(Config{Server: "irc.example.com:6697"})
+	require.NoError(t, err)
+	require.Nil(t, tlsConfig)
+
+	tlsConfig, err = newTLSConfig(Config{
+		Server:         "irc.example.com:6697",
+		UseTLS:         true,
+		RootCAPath:     certPath,
+		ClientCertPath: certPath,
+		ClientKeyPath:  keyPath,
+		TLSServerName:  "irc.internal",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "irc.internal", tlsConfig.ServerName)
+	require.NotNil(t, tlsConfig.RootCAs)
+	require.Len(t, tlsConfig.Certificates, 1)
+
+	_, err = newTLSConfig(Config{
+		Server:         "irc.example.com:6697",
+		UseTLS:         true,
+		ClientCertPath: certPath,
+	})
+	require.Error(t, err)
+
+	_, err = newTLSConfig(Config{
+		Server:     "irc.example.com:6697",
+		UseTLS:     true,
+		RootCAPath: keyPath,
+	})
+	require.Error(t, err)
+}
+
+func TestDialTLS(t *testing.T) {
+	certPath, keyPath := writeTestCertificate(t, t.TempDir())
+
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	require.NoError(t, err)
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	require.NoError(t, err)
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.(*tls.Conn).Handshake()
+	}()
+
+	c := newTestConnection(t, Config{
+		Server:        ln.Addr().String(),
+		UseTLS:        true,
+		RootCAPath:    certPath,
+		TLSServerName: "irc.example.com",
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	conn, err := c.dialContext(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	status := newTLSStatus(conn.(*tls.Conn).ConnectionState(), c.tlsConfig)
+	require.Equal(t, "TLS 1.3", status.Version)
+	require.Equal(t, certFingerprint(cert.Certificate[0]), status.PeerCertFingerprint)
+}
@@ -0,0 +1,107 @@
+package irc
+
+import (
+	"context"
+	"time"
+
+	"github.com/kyleterry/tenyks/pkg/logger"
+	"github.com/kyleterry/tenyks/pkg/message"
+)
+
+// defaultTopicReplyHandler records the topic the server sends when we join a
+// channel or ask for its topic.
+func defaultTopicReplyHandler(ctx context.Context, c *Connection, reply Reply) error {
+	switch r := reply.(type) {
+	case *TopicReply:
+		if err := r.Validate(); err != nil {
+			return err
+		}
+
+		c.WithWriteLock(ctx, func(conn *Connection) {
+			if channel, ok := conn.channel(r.Channel()); ok {
+				channel.Status.Topic = r.Topic()
+			}
+		})
+	case *TopicWhoTimeReply:
+		if err := r.Validate(); err != nil {
+			return err
+		}
+
+		c.WithWriteLock(ctx, func(conn *Connection) {
+			if channel, ok := conn.channel(r.Channel()); ok {
+				channel.Status.TopicSetBy = r.SetBy()
+				channel.Status.TopicSetAt = r.SetAt()
+			}
+		})
+	case *NoTopicReply:
+		if err := r.Validate(); err != nil {
+			return err
+		}
+
+		c.WithWriteLock(ctx, func(conn *Connection) {
+			if channel, ok := conn.channel(r.Channel()); ok {
+				channel.Status.Topic = ""
+				channel.Status.TopicSetBy = ""
+				channel.Status.TopicSetAt = time.Time{}
+			}
+		})
+	}
+
+	return nil
+}
+
+// defaultTopicChangeHandler records topic changes and tells services about
+// them with a topic event.
+func defaultTopicChangeHandler(ctx context.Context, c *Connection, command Command) error {
+	cmd, ok := command.(*TopicCommand)
+	if !ok {
+		return nil
+	}
+
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	msg := cmd.Message()
+
+	var setBy string
+	if msg.PrefixSection != nil {
+		setBy = msg.PrefixSection.RawPrefix
+	}
+
+	var tracked bool
+
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		if channel, ok := conn.channel(cmd.Channel()); ok {
+			tracked = true
+			channel.Status.Topic = cmd.Topic()
+			channel.Status.TopicSetBy = setBy
+			channel.Status.TopicSetAt = now
+		}
+	})
+
+	if !tracked {
+		return nil
+	}
+
+	c.log.Debug("topic changed",
+		logger.Param{Key: "channel", Value: cmd.Channel()},
+		logger.Param{Key: "by", Value: setBy})
+
+	event := &message.EventMessage{
+		Kind:       message.EventKindTopic,
+		TargetPath: c.targetPath(cmd.Channel()),
+		Content:    cmd.Topic(),
+		Attributes: map[string]string{"setBy": setBy},
+		Timestamp:  now,
+	}
+
+	if nick := prefixNick(msg); nick != "" {
+		event.OriginPath = c.targetPath(nick)
+	}
+
+	c.dispatchMessage(event)
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package irc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kyleterry/tenyks/pkg/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopicTracking(t *testing.T) {
+	c := newTestConnection(t, Config{Name: "test", Channels: []string{"#tenyks"}})
+	c.Status.CurrentNick = "tenyks"
+
+	events := []*message.EventMessage{}
+	c.RegisterMessageHandler(func(msg message.Message) {
+		if event, ok := msg.(*message.EventMessage); ok {
+			events = append(events, event)
+		}
+	})
+
+	dispatch(t, c, ":tenyks!~tenyks@example.com JOIN #tenyks")
+	dispatch(t, c, ":irc.test 332 tenyks #tenyks :welcome to tenyks")
+	dispatch(t, c, ":irc.test 333 tenyks #tenyks alice!~alice@example.com 1600000000")
+
+	status := c.channels["#tenyks"].Status
+
+	require.Equal(t, "welcome to tenyks", status.Topic)
+	require.Equal(t, "alice!~alice@example.com", status.TopicSetBy)
+	require.Equal(t, time.Unix(1600000000, 0), status.TopicSetAt)
+	require.Empty(t, events)
+
+	dispatch(t, c, ":bob!~bob@example.com TOPIC #tenyks :tenyks v2 is out")
+
+	require.Equal(t, "tenyks v2 is out", status.Topic)
+	require.Equal(t, "bob!~bob@example.com", status.TopicSetBy)
+	require.Len(t, events, 1)
+	require.Equal(t, message.EventKindTopic, events[0].Kind)
+	require.Equal(t, "/irc/test/#tenyks", events[0].TargetPath)
+	require.Equal(t, "/irc/test/bob", events[0].OriginPath)
+	require.Equal(t, "tenyks v2 is out", events[0].Content)
+
+	dispatch(t, c, ":irc.test 331 tenyks #tenyks :No topic is set")
+
+	require.Empty(t, status.Topic)
+	require.True(t, status.TopicSetAt.IsZero())
+
+	raw, err := NewTopicCommand("#tenyks", "new topic").Encode()
+	require.NoError(t, err)
+	require.Equal(t, "TOPIC #tenyks :new topic\r\n", raw)
+
+	raw, err = NewTopicCommand("#tenyks", "").Encode()
+	require.NoError(t, err)
+	require.Equal(t, "TOPIC #tenyks\r\n", raw)
+
+	raw, err = NewClearTopicCommand("#tenyks").Encode()
+	require.NoError(t, err)
+	require.Equal(t, "TOPIC #tenyks :\r\n", raw)
+}
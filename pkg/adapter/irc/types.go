@@ -13,20 +13,48 @@ const (
 	CommandTypePrivmsg
 	CommandTypePing
 	CommandTypePong
-	CommandTypeCTCP
+	CommandTypeCap
+	CommandTypeAuthenticate
+	CommandTypeError
+	CommandTypeQuit
+	CommandTypeKick
+	CommandTypeMode
+	CommandTypeTopic
+	CommandTypeNotice
+	CommandTypeInvite
+	CommandTypeWho
+	CommandTypeAccount
+	CommandTypeAway
+	CommandTypeChghost
+	CommandTypeWhois
+	CommandTypeTagmsg
 	CommandTypeUnknown
 )
 
 var CommandTypeMapping = map[string]CommandType{
-	"USER":    CommandTypeUser,
-	"NICK":    CommandTypeNick,
-	"JOIN":    CommandTypeJoin,
-	"PART":    CommandTypePart,
-	"PASS":    CommandTypePass,
-	"PRIVMSG": CommandTypePrivmsg,
-	"PING":    CommandTypePing,
-	"PONG":    CommandTypePong,
-	"CTCP":    CommandTypeCTCP,
+	"USER":         CommandTypeUser,
+	"NICK":         CommandTypeNick,
+	"JOIN":         CommandTypeJoin,
+	"PART":         CommandTypePart,
+	"PASS":         CommandTypePass,
+	"PRIVMSG":      CommandTypePrivmsg,
+	"PING":         CommandTypePing,
+	"PONG":         CommandTypePong,
+	"CAP":          CommandTypeCap,
+	"AUTHENTICATE": CommandTypeAuthenticate,
+	"ERROR":        CommandTypeError,
+	"QUIT":         CommandTypeQuit,
+	"KICK":         CommandTypeKick,
+	"MODE":         CommandTypeMode,
+	"TOPIC":        CommandTypeTopic,
+	"NOTICE":       CommandTypeNotice,
+	"INVITE":       CommandTypeInvite,
+	"WHO":          CommandTypeWho,
+	"ACCOUNT":      CommandTypeAccount,
+	"AWAY":         CommandTypeAway,
+	"CHGHOST":      CommandTypeChghost,
+	"WHOIS":        CommandTypeWhois,
+	"TAGMSG":       CommandTypeTagmsg,
 }
 
 // ReplyType represents a reply to a command. These can be successful replies
@@ -38,7 +66,7 @@ const (
 	ReplyTypeYourHost
 	ReplyTypeCreated
 	ReplyTypeMyInfo
-	ReplyTypeBounce
+	ReplyTypeISupport
 	ReplyTypeNoTopic
 	ReplyTypeTopic
 	ReplyTypeNames
@@ -46,6 +74,44 @@ const (
 	ReplyTypeErrNoSuchNick
 	ReplyTypeErrErroneusNickname
 	ReplyTypeErrNickInUse
+	ReplyTypeErrNickCollision
+	ReplyTypeErrUnavailResource
+	ReplyTypeIson
+	ReplyTypeMonOnline
+	ReplyTypeMonOffline
+	ReplyTypeLoggedIn
+	ReplyTypeErrNickLocked
+	ReplyTypeSASLSuccess
+	ReplyTypeErrSASLFail
+	ReplyTypeErrSASLTooLong
+	ReplyTypeErrSASLAborted
+	ReplyTypeErrSASLAlready
+	ReplyTypeChannelModeIs
+	ReplyTypeBanList
+	ReplyTypeEndOfBanList
+	ReplyTypeExceptList
+	ReplyTypeEndOfExceptList
+	ReplyTypeInviteList
+	ReplyTypeEndOfInviteList
+	ReplyTypeTopicWhoTime
+	ReplyTypeErrChannelIsFull
+	ReplyTypeErrInviteOnlyChan
+	ReplyTypeErrBannedFromChan
+	ReplyTypeErrBadChannelKey
+	ReplyTypeErrNeedReggedNick
+	ReplyTypeWho
+	ReplyTypeWhox
+	ReplyTypeEndOfWho
+	ReplyTypeWhoisUser
+	ReplyTypeWhoisChannels
+	ReplyTypeWhoisAccount
+	ReplyTypeEndOfWhois
+	ReplyTypeEndOfMOTD
+	ReplyTypeErrNoMOTD
+	ReplyTypeErrNoSuchChannel
+	ReplyTypeErrTooManyChannels
+	ReplyTypeErrBadChanMask
+	ReplyTypeErrCannotSendToChan
 )
 
 var ReplyTypeMapping = map[string]ReplyType{
@@ -53,12 +119,50 @@ var ReplyTypeMapping = map[string]ReplyType{
 	"002": ReplyTypeYourHost,
 	"003": ReplyTypeCreated,
 	"004": ReplyTypeMyInfo,
-	"005": ReplyTypeBounce,
+	"005": ReplyTypeISupport,
+	"303": ReplyTypeIson,
+	"311": ReplyTypeWhoisUser,
+	"315": ReplyTypeEndOfWho,
+	"318": ReplyTypeEndOfWhois,
+	"319": ReplyTypeWhoisChannels,
+	"324": ReplyTypeChannelModeIs,
+	"330": ReplyTypeWhoisAccount,
 	"331": ReplyTypeNoTopic,
 	"332": ReplyTypeTopic,
+	"333": ReplyTypeTopicWhoTime,
+	"346": ReplyTypeInviteList,
+	"347": ReplyTypeEndOfInviteList,
+	"348": ReplyTypeExceptList,
+	"349": ReplyTypeEndOfExceptList,
+	"352": ReplyTypeWho,
 	"353": ReplyTypeNames,
+	"354": ReplyTypeWhox,
 	"366": ReplyTypeEndOfNames,
+	"367": ReplyTypeBanList,
+	"368": ReplyTypeEndOfBanList,
+	"376": ReplyTypeEndOfMOTD,
 	"401": ReplyTypeErrNoSuchNick,
+	"403": ReplyTypeErrNoSuchChannel,
+	"404": ReplyTypeErrCannotSendToChan,
+	"405": ReplyTypeErrTooManyChannels,
+	"422": ReplyTypeErrNoMOTD,
 	"432": ReplyTypeErrErroneusNickname,
 	"433": ReplyTypeErrNickInUse,
+	"436": ReplyTypeErrNickCollision,
+	"437": ReplyTypeErrUnavailResource,
+	"471": ReplyTypeErrChannelIsFull,
+	"473": ReplyTypeErrInviteOnlyChan,
+	"474": ReplyTypeErrBannedFromChan,
+	"475": ReplyTypeErrBadChannelKey,
+	"476": ReplyTypeErrBadChanMask,
+	"477": ReplyTypeErrNeedReggedNick,
+	"730": ReplyTypeMonOnline,
+	"731": ReplyTypeMonOffline,
+	"900": ReplyTypeLoggedIn,
+	"902": ReplyTypeErrNickLocked,
+	"903": ReplyTypeSASLSuccess,
+	"904": ReplyTypeErrSASLFail,
+	"905": ReplyTypeErrSASLTooLong,
+	"906": ReplyTypeErrSASLAborted,
+	"907": ReplyTypeErrSASLAlready,
 }
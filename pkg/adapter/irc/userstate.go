@@ -0,0 +1,103 @@
+package irc
+
+import (
+	"context"
+
+	"github.com/kyleterry/tenyks/pkg/logger"
+)
+
+// senderAccount returns the services account of the user that sent msg. The
+// account tag is used when the server sends it, otherwise we go by what we
+// know about the sender from our channels.
+func (c *Connection) senderAccount(msg *Message) string {
+	if account, ok := msg.Tag("account"); ok {
+		return account
+	}
+
+	nick := prefixNick(msg)
+	if nick == "" {
+		return ""
+	}
+
+	var account string
+
+	c.RLock()
+	defer c.RUnlock()
+
+	c.forEachMember(nick, func(member *Nick) {
+		if member.Account != "" {
+			account = member.Account
+		}
+	})
+
+	return account
+}
+
+// defaultUserStateTracker keeps channel members up to date with the ACCOUNT,
+// AWAY and CHGHOST commands the server sends with account-notify,
+// away-notify and chghost, and with the account tag on anything they send.
+func defaultUserStateTracker(ctx context.Context, c *Connection, command Command) error {
+	msg := command.Message()
+
+	nick := prefixNick(msg)
+	if nick == "" {
+		return nil
+	}
+
+	self := c.isSelf(nick)
+
+	switch cmd := command.(type) {
+	case *AccountCommand:
+		if err := cmd.Validate(); err != nil {
+			return err
+		}
+
+		c.WithWriteLock(ctx, func(conn *Connection) {
+			conn.forEachMember(nick, func(member *Nick) {
+				member.Account = cmd.Account()
+			})
+
+			if self {
+				conn.Status.Account = cmd.Account()
+			}
+		})
+
+		c.log.Debug("account changed",
+			logger.Param{Key: "nick", Value: nick},
+			logger.Param{Key: "account", Value: cmd.Account()})
+	case *AwayCommand:
+		c.WithWriteLock(ctx, func(conn *Connection) {
+			conn.forEachMember(nick, func(member *Nick) {
+				member.Away = cmd.IsAway()
+			})
+		})
+	case *ChghostCommand:
+		if err := cmd.Validate(); err != nil {
+			return err
+		}
+
+		c.WithWriteLock(ctx, func(conn *Connection) {
+			conn.forEachMember(nick, func(member *Nick) {
+				member.Ident = cmd.Ident()
+				member.Host = cmd.Host()
+			})
+
+			if self {
+				conn.Status.Hostmask = nick + "!" + cmd.Ident() + "@" + cmd.Host()
+			}
+		})
+	default:
+		account, ok := msg.Tag("account")
+		if !ok {
+			return nil
+		}
+
+		c.WithWriteLock(ctx, func(conn *Connection) {
+			conn.forEachMember(nick, func(member *Nick) {
+				member.Account = account
+			})
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package irc
+
+import (
+	"testing"
+
+	"github.com/kyleterry/tenyks/pkg/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserStateTracking(t *testing.T) {
+	c := newTestConnection(t, Config{Channels: []string{"#tenyks", "#other"}})
+	c.Status.CurrentNick = "tenyks"
+
+	msgs := []*message.ChatMessage{}
+	c.RegisterMessageHandler(func(msg message.Message) {
+		msgs = append(msgs, msg.(*message.ChatMessage))
+	})
+
+	dispatch(t, c, ":tenyks!~tenyks@example.com JOIN #tenyks * :tenyks bot")
+	dispatch(t, c, ":tenyks!~tenyks@example.com JOIN #other * :tenyks bot")
+	dispatch(t, c, ":alice!~alice@example.com JOIN #tenyks alice :Alice Liddell")
+	dispatch(t, c, ":alice!~alice@example.com JOIN #other alice :Alice Liddell")
+	dispatch(t, c, ":bob!~bob@example.com JOIN #tenyks * :Bob")
+
+	tenyks, other := c.channels["#tenyks"].Status, c.channels["#other"].Status
+
+	require.Equal(t, "alice", tenyks.Nicks["alice"].Account)
+	require.Equal(t, "Alice Liddell", other.Nicks["alice"].RealName)
+	require.Empty(t, tenyks.Nicks["bob"].Account)
+
+	dispatch(t, c, ":bob!~bob@example.com ACCOUNT bob")
+	dispatch(t, c, ":alice!~alice@example.com AWAY :lunch")
+	dispatch(t, c, ":alice!~alice@example.com CHGHOST alice staff.example.com")
+
+	require.Equal(t, "bob", tenyks.Nicks["bob"].Account)
+	require.True(t, other.Nicks["alice"].Away)
+	require.Equal(t, "alice!alice@staff.example.com", other.Nicks["alice"].Hostmask())
+
+	dispatch(t, c, ":alice!alice@staff.example.com AWAY")
+	dispatch(t, c, ":alice!alice@staff.example.com ACCOUNT *")
+
+	require.False(t, tenyks.Nicks["alice"].Away)
+	require.Empty(t, tenyks.Nicks["alice"].Account)
+
+	dispatch(t, c, ":tenyks!~tenyks@example.com CHGHOST tenyks bots.example.com")
+	require.Equal(t, "tenyks!tenyks@bots.example.com", c.Status.Hostmask)
+
+	// the account tag wins over what we have on record
+	dispatch(t, c, "@account=alice :alice!alice@staff.example.com PRIVMSG #tenyks :hello")
+	dispatch(t, c, ":bob!~bob@example.com PRIVMSG #tenyks :hi")
+	dispatch(t, c, ":mallory!~m@example.com PRIVMSG #tenyks :hey")
+
+	require.Len(t, msgs, 3)
+	require.Equal(t, "alice", msgs[0].Account)
+	require.Equal(t, "alice", tenyks.Nicks["alice"].Account)
+	require.Equal(t, "bob", msgs[1].Account)
+	require.Empty(t, msgs[2].Account)
+}
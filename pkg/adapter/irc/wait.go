@@ -0,0 +1,386 @@
+package irc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ReplyError is the error numeric the server answered a request with. It
+// wraps ErrRequestFailed, and Reply is the typed reply, like
+// *ErrJoinFailedReply or *ErrNickInUseReply.
+type ReplyError struct {
+	Reply Reply
+}
+
+func (e *ReplyError) Error() string {
+	m := e.Reply.Message()
+
+	if m.Trail == "" {
+		return fmt.Sprintf("%s: %s", ErrRequestFailed, m.Command)
+	}
+
+	return fmt.Sprintf("%s: %s %s", ErrRequestFailed, m.Command, m.Trail)
+}
+
+func (e *ReplyError) Unwrap() error {
+	return ErrRequestFailed
+}
+
+// Response is what the server sent back about a command passed to
+// SendAndWait, in the order it arrived. The last message is the one that
+// finished the request.
+type Response struct {
+	Messages []MessageObject
+}
+
+// expectation decides which received messages answer a request. It returns
+// matched for messages that belong to the answer, done once the answer is
+// complete and an error if the server refused the request. It's called with
+// the connection's lock held.
+type expectation func(conn *Connection, mo MessageObject) (matched, done bool, err error)
+
+// waiter is a request from SendAndWait that hasn't been answered yet.
+type waiter struct {
+	expect   expectation
+	response *Response
+	err      error
+	done     chan struct{}
+}
+
+// SendAndWait enqueues cmd and blocks until the server answers it, the
+// session ends or ctx is done. JOIN is answered by our own JOIN echo for
+// every channel or an error like ERR_BANNEDFROMCHAN, NICK by the NICK echo or
+// an error like ERR_NICKNAMEINUSE, WHOIS by RPL_ENDOFWHOIS or
+// ERR_NOSUCHNICK and PING by the PONG with the same token.
+//
+// ctx also bounds the wait for room on the send queue, which fills up while
+// we're disconnected or flood control is holding back a backlog.
+//
+// Errors from the server are returned as a *ReplyError along with the
+// response. Other commands return ErrNoReplyExpected without being sent.
+//
+// Hooks must never call SendAndWait directly. OnCommand, OnReply and
+// OnRegistered hooks all run on the dispatcher, the one goroutine that
+// hands received messages to waiting requests, so the answer can't arrive
+// until the hook returns: the call only ends when ctx is done and nothing
+// else is received in the meantime. Start a goroutine and call it from
+// there instead.
+func (c *Connection) SendAndWait(ctx context.Context, cmd Command) (*Response, error) {
+	if err := cmd.Validate(); err != nil {
+		return nil, fmt.Errorf("failed to enqueue command; validation failed: %w", err)
+	}
+
+	expect, err := expectationFor(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &waiter{
+		expect:   expect,
+		response: &Response{},
+		done:     make(chan struct{}),
+	}
+
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		conn.waiters = append(conn.waiters, w)
+	})
+
+	if err := c.enqueueCommand(ctx, cmd); err != nil {
+		c.removeWaiter(ctx, w)
+
+		return nil, err
+	}
+
+	select {
+	case <-w.done:
+		return w.response, w.err
+	case <-ctx.Done():
+		c.removeWaiter(ctx, w)
+
+		return nil, ctx.Err()
+	}
+}
+
+// WhoisUser is what a WHOIS tells us about a user.
+type WhoisUser struct {
+	Nick     string
+	Ident    string
+	Host     string
+	RealName string
+	// Account is the services account the user is logged into, if any.
+	Account string
+	// Channels are the channels the user is in that we can see, with their
+	// prefixes.
+	Channels []string
+}
+
+// Whois asks the server about nick and waits for the answer. The error wraps
+// ErrRequestFailed if there's no such nick.
+func (c *Connection) Whois(ctx context.Context, nick string) (*WhoisUser, error) {
+	response, err := c.SendAndWait(ctx, NewWhoisCommand(nick))
+	if err != nil {
+		return nil, err
+	}
+
+	user := &WhoisUser{Nick: nick}
+
+	for _, mo := range response.Messages {
+		switch r := mo.(type) {
+		case *WhoisUserReply:
+			user.Nick = r.Nick()
+			user.Ident = r.Ident()
+			user.Host = r.Host()
+			user.RealName = r.RealName()
+		case *WhoisAccountReply:
+			user.Account = r.Account()
+		case *WhoisChannelsReply:
+			user.Channels = append(user.Channels, r.Channels()...)
+		}
+	}
+
+	return user, nil
+}
+
+// expectationFor returns the expectation that matches the answer to cmd.
+func expectationFor(cmd Command) (expectation, error) {
+	switch cmd := cmd.(type) {
+	case *JoinCommand:
+		// JOIN 0 parts every channel instead
+		if cmd.Channel() == "0" {
+			break
+		}
+
+		return expectJoin(strings.Split(cmd.Channel(), ",")), nil
+	case *NickCommand:
+		return expectNick(cmd.Nick()), nil
+	case *WhoisCommand:
+		return expectWhois(cmd.Nick()), nil
+	case *PingCommand:
+		return expectPong(cmd.Message().Trail), nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrNoReplyExpected, cmd.Message().Command)
+}
+
+// expectJoin is done once every channel was either joined or refused. The
+// first refusal is returned.
+func expectJoin(channels []string) expectation {
+	pending := map[string]bool{}
+	for _, name := range channels {
+		pending[name] = true
+	}
+
+	var failed error
+
+	// resolve removes the channel matching name and reports if it was one of
+	// ours.
+	resolve := func(conn *Connection, name string) bool {
+		for channel := range pending {
+			if conn.features.CaseMapping.Equal(channel, name) {
+				delete(pending, channel)
+
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return func(conn *Connection, mo MessageObject) (bool, bool, error) {
+		switch m := mo.(type) {
+		case *JoinCommand:
+			if m.Validate() != nil || !conn.isCurrentNick(prefixNick(m.Message())) {
+				return false, false, nil
+			}
+
+			if !resolve(conn, m.Channel()) {
+				return false, false, nil
+			}
+		case *ErrJoinFailedReply:
+			if m.Validate() != nil || !resolve(conn, m.Channel()) {
+				return false, false, nil
+			}
+
+			if failed == nil {
+				failed = &ReplyError{Reply: m}
+			}
+		case *ErrUnavailResourceReply:
+			if !resolve(conn, m.Nick()) {
+				return false, false, nil
+			}
+
+			if failed == nil {
+				failed = &ReplyError{Reply: m}
+			}
+		default:
+			return false, false, nil
+		}
+
+		return true, len(pending) == 0, failed
+	}
+}
+
+// expectNick is done when the server echoes our nick change or refuses it.
+// The echo comes from our old nick, so it's matched on the new one, which
+// is current by the time we see it.
+func expectNick(nick string) expectation {
+	return func(conn *Connection, mo MessageObject) (bool, bool, error) {
+		var rejected string
+
+		switch m := mo.(type) {
+		case *NickCommand:
+			if m.Validate() != nil || prefixNick(m.Message()) == "" {
+				return false, false, nil
+			}
+
+			if conn.features.CaseMapping.Equal(m.Nick(), nick) && conn.isCurrentNick(nick) {
+				return true, true, nil
+			}
+
+			return false, false, nil
+		case *ErrErroneusNicknameReply:
+			rejected = m.Nick()
+		case *ErrNickInUseReply:
+			rejected = m.Nick()
+		case *ErrNickCollisionReply:
+			rejected = m.Nick()
+		case *ErrUnavailResourceReply:
+			rejected = m.Nick()
+		default:
+			return false, false, nil
+		}
+
+		if !conn.features.CaseMapping.Equal(rejected, nick) {
+			return false, false, nil
+		}
+
+		return true, true, &ReplyError{Reply: mo.(Reply)}
+	}
+}
+
+// expectWhois collects the WHOIS replies about nick until RPL_ENDOFWHOIS.
+func expectWhois(nick string) expectation {
+	return func(conn *Connection, mo MessageObject) (bool, bool, error) {
+		var about string
+
+		switch m := mo.(type) {
+		case *WhoisUserReply:
+			if m.Validate() != nil {
+				return false, false, nil
+			}
+
+			about = m.Nick()
+		case *WhoisChannelsReply:
+			if m.Validate() != nil {
+				return false, false, nil
+			}
+
+			about = m.Nick()
+		case *WhoisAccountReply:
+			if m.Validate() != nil {
+				return false, false, nil
+			}
+
+			about = m.Nick()
+		case *EndOfWhoisReply:
+			if m.Validate() != nil || !conn.features.CaseMapping.Equal(m.Nick(), nick) {
+				return false, false, nil
+			}
+
+			return true, true, nil
+		case *ErrNoSuchNickReply:
+			if !conn.features.CaseMapping.Equal(m.Nick(), nick) {
+				return false, false, nil
+			}
+
+			return true, true, &ReplyError{Reply: m}
+		default:
+			return false, false, nil
+		}
+
+		return conn.features.CaseMapping.Equal(about, nick), false, nil
+	}
+}
+
+// expectPong is done when the server answers our PING with token.
+func expectPong(token string) expectation {
+	return func(conn *Connection, mo MessageObject) (bool, bool, error) {
+		pong, ok := mo.(*PongCommand)
+		if !ok || pong.Token() != token {
+			return false, false, nil
+		}
+
+		return true, true, nil
+	}
+}
+
+// removeWaiter forgets a request that's no longer being waited on.
+func (c *Connection) removeWaiter(ctx context.Context, w *waiter) {
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		for i, waiting := range conn.waiters {
+			if waiting == w {
+				conn.waiters = append(conn.waiters[:i], conn.waiters[i+1:]...)
+
+				return
+			}
+		}
+	})
+}
+
+// resolveWaiters hands mo to every waiting request and wakes up the ones it
+// finishes.
+func (c *Connection) resolveWaiters(ctx context.Context, mo MessageObject) {
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		waiters := conn.waiters[:0]
+
+		for _, w := range conn.waiters {
+			matched, done, err := w.expect(conn, mo)
+			if matched {
+				w.response.Messages = append(w.response.Messages, mo)
+			}
+
+			if done {
+				w.err = err
+				close(w.done)
+
+				continue
+			}
+
+			waiters = append(waiters, w)
+		}
+
+		conn.waiters = waiters
+	})
+}
+
+// defaultCommandWaiterResolver finishes requests answered by a command, like
+// our own JOIN. It runs after the other hooks so the connection's state is
+// up to date when SendAndWait returns.
+func defaultCommandWaiterResolver(ctx context.Context, c *Connection, command Command) error {
+	c.resolveWaiters(ctx, command)
+
+	return nil
+}
+
+// defaultReplyWaiterResolver finishes requests answered by a reply.
+func defaultReplyWaiterResolver(ctx context.Context, c *Connection, reply Reply) error {
+	c.resolveWaiters(ctx, reply)
+
+	return nil
+}
+
+// resetWaiters fails every request still waiting when the session ends.
+// Their commands may never have reached the server.
+func resetWaiters(ctx context.Context, c *Connection) error {
+	c.WithWriteLock(ctx, func(conn *Connection) {
+		for _, w := range conn.waiters {
+			w.err = ErrDisconnected
+			close(w.done)
+		}
+
+		conn.waiters = nil
+	})
+
+	return nil
+}
@@ -0,0 +1,237 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type waitResult struct {
+	response *Response
+	err      error
+}
+
+// sendAndWait starts SendAndWait in the background and returns once cmd has
+// been queued.
+func sendAndWait(t *testing.T, ctx context.Context, c *Connection, cmd Command) (string, <-chan waitResult) {
+	t.Helper()
+
+	result := make(chan waitResult, 1)
+
+	go func() {
+		response, err := c.SendAndWait(ctx, cmd)
+		result <- waitResult{response: response, err: err}
+	}()
+
+	lines := waitForCommands(t, c, 1)
+	require.Len(t, lines, 1)
+
+	return lines[0], result
+}
+
+func TestSendAndWaitJoin(t *testing.T) {
+	ctx := context.Background()
+
+	c := newTestConnection(t, Config{})
+	c.Status.CurrentNick = "tenyks"
+
+	line, result := sendAndWait(t, ctx, c, NewJoinCommand("#tenyks", "#banned"))
+	require.Equal(t, "JOIN #tenyks,#banned\r\n", line)
+
+	// someone else joining doesn't count
+	dispatch(t, c, ":alice!~alice@example.com JOIN #tenyks")
+	dispatch(t, c, ":tenyks!~tenyks@example.com JOIN #Tenyks")
+
+	select {
+	case <-result:
+		t.Fatal("resolved before every channel was answered")
+	default:
+	}
+
+	dispatch(t, c, ":irc.test 474 tenyks #banned :Cannot join channel (+b)")
+
+	r := <-result
+	require.True(t, errors.Is(r.err, ErrRequestFailed))
+	require.EqualError(t, r.err, "server refused the request: 474 Cannot join channel (+b)")
+
+	var replyErr *ReplyError
+	require.True(t, errors.As(r.err, &replyErr))
+	require.IsType(t, &ErrJoinFailedReply{}, replyErr.Reply)
+
+	require.Len(t, r.response.Messages, 2)
+	require.IsType(t, &JoinCommand{}, r.response.Messages[0])
+	require.Empty(t, c.waiters)
+
+	t.Run("no such channel", func(t *testing.T) {
+		drainCommands(t, c)
+
+		_, result := sendAndWait(t, ctx, c, NewJoinCommand("#nope", "bad"))
+
+		dispatch(t, c, ":irc.test 403 tenyks #nope :No such channel")
+		dispatch(t, c, ":irc.test 476 tenyks bad :Bad Channel Mask")
+
+		r := <-result
+
+		var replyErr *ReplyError
+		require.True(t, errors.As(r.err, &replyErr))
+		require.Equal(t, "403", replyErr.Reply.Message().Command)
+		require.Len(t, r.response.Messages, 2)
+	})
+}
+
+func TestSendAndWaitFromHook(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := newTestConnection(t, Config{})
+	c.Status.CurrentNick = "tenyks"
+
+	errs := make(chan error, 1)
+
+	c.OnCommand = append(c.OnCommand, func(ctx context.Context, c *Connection, command Command) error {
+		invite, ok := command.(*InviteCommand)
+		if !ok {
+			return nil
+		}
+
+		// the answer is dispatched by the goroutine running this hook
+		go func() {
+			_, err := c.SendAndWait(ctx, NewJoinCommand(invite.Channel()))
+			errs <- err
+		}()
+
+		return nil
+	})
+
+	c.temporaryDispatcher(ctx)
+
+	c.in <- mustDecode(t, c, ":alice!~alice@example.com INVITE tenyks #new")
+	require.Equal(t, []string{"JOIN #new\r\n"}, waitForCommands(t, c, 1))
+
+	c.in <- mustDecode(t, c, ":tenyks!~tenyks@example.com JOIN #new")
+	require.NoError(t, <-errs)
+}
+
+func TestSendAndWaitNick(t *testing.T) {
+	ctx := context.Background()
+
+	c := newTestConnection(t, Config{})
+	c.Status.CurrentNick = "tenyks"
+
+	line, result := sendAndWait(t, ctx, c, NewNickCommand("tenyks_"))
+	require.Equal(t, "NICK tenyks_\r\n", line)
+
+	dispatch(t, c, ":tenyks!~tenyks@example.com NICK tenyks_")
+
+	r := <-result
+	require.NoError(t, r.err)
+	require.Len(t, r.response.Messages, 1)
+	require.Equal(t, "tenyks_", c.Status.CurrentNick)
+
+	t.Run("in use", func(t *testing.T) {
+		_, result := sendAndWait(t, ctx, c, NewNickCommand("alice"))
+
+		dispatch(t, c, ":irc.test 433 tenyks_ bob :Nickname is already in use")
+		dispatch(t, c, ":irc.test 433 tenyks_ alice :Nickname is already in use")
+
+		r := <-result
+
+		var replyErr *ReplyError
+		require.True(t, errors.As(r.err, &replyErr))
+		require.IsType(t, &ErrNickInUseReply{}, replyErr.Reply)
+	})
+}
+
+func TestWhois(t *testing.T) {
+	ctx := context.Background()
+
+	c := newTestConnection(t, Config{})
+	c.Status.CurrentNick = "tenyks"
+
+	var (
+		user *WhoisUser
+		err  error
+	)
+
+	done := make(chan struct{})
+
+	go func() {
+		user, err = c.Whois(ctx, "alice")
+		close(done)
+	}()
+
+	require.Equal(t, []string{"WHOIS alice\r\n"}, waitForCommands(t, c, 1))
+
+	dispatch(t, c, ":irc.test 311 tenyks Alice ~alice example.com * :Alice Liddell")
+	dispatch(t, c, ":irc.test 319 tenyks Alice :@#tenyks #other")
+	dispatch(t, c, ":irc.test 311 tenyks bob ~bob example.com * :Bob")
+	dispatch(t, c, ":irc.test 330 tenyks Alice alice :is logged in as")
+	dispatch(t, c, ":irc.test 318 tenyks Alice :End of /WHOIS list.")
+
+	<-done
+	require.NoError(t, err)
+	require.Equal(t, &WhoisUser{
+		Nick:     "Alice",
+		Ident:    "~alice",
+		Host:     "example.com",
+		RealName: "Alice Liddell",
+		Account:  "alice",
+		Channels: []string{"@#tenyks", "#other"},
+	}, user)
+
+	t.Run("no such nick", func(t *testing.T) {
+		errs := make(chan error, 1)
+
+		go func() {
+			_, err := c.Whois(ctx, "carol")
+			errs <- err
+		}()
+
+		waitForCommands(t, c, 1)
+		dispatch(t, c, ":irc.test 401 tenyks carol :No such nick/channel")
+
+		require.True(t, errors.Is(<-errs, ErrRequestFailed))
+	})
+}
+
+func TestSendAndWaitCancelled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+
+	c := newTestConnection(t, Config{})
+
+	_, result := sendAndWait(t, ctx, c, NewPingCommand("tenyks-1"))
+
+	r := <-result
+	require.True(t, errors.Is(r.err, context.DeadlineExceeded))
+	require.Empty(t, c.waiters)
+
+	t.Run("disconnect", func(t *testing.T) {
+		_, result := sendAndWait(t, context.Background(), c, NewPingCommand("tenyks-2"))
+
+		require.NoError(t, resetWaiters(context.Background(), c))
+		require.True(t, errors.Is((<-result).err, ErrDisconnected))
+	})
+
+	t.Run("no reply expected", func(t *testing.T) {
+		_, err := c.SendAndWait(context.Background(), NewPrivmsgCommand("#tenyks", "hi"))
+		require.True(t, errors.Is(err, ErrNoReplyExpected))
+		require.Empty(t, drainCommands(t, c))
+	})
+
+	t.Run("queue full", func(t *testing.T) {
+		c := newTestConnection(t, Config{})
+		c.out = make(chan Command, 1)
+		require.NoError(t, c.EnqueueCommand(NewPrivmsgCommand("#tenyks", "backlog")))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+		defer cancel()
+
+		_, err := c.SendAndWait(ctx, NewWhoisCommand("alice"))
+		require.True(t, errors.Is(err, context.DeadlineExceeded))
+		require.Empty(t, c.waiters)
+	})
+}
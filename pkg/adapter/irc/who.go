@@ -0,0 +1,115 @@
+package irc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/kyleterry/tenyks/pkg/logger"
+)
+
+const (
+	// whoxToken marks replies to our own WHOX queries. Replies to queries
+	// with other fields are left alone.
+	whoxToken = "152"
+	// whoxFields asks for the token, channel, ident, host, server, nick,
+	// flags, account and real name of every user.
+	whoxFields = "%tcuhsnfar," + whoxToken
+)
+
+// WhoUser is what a WHO or WHOX reply tells us about a user.
+type WhoUser struct {
+	Channel  string
+	Nick     string
+	Ident    string
+	Host     string
+	Server   string
+	RealName string
+	Account  string
+	// Flags start with H if the user is here or G if they're away, followed
+	// by * for IRC operators and their prefixes in Channel.
+	Flags string
+}
+
+// Away returns true if the user is marked as away.
+func (u WhoUser) Away() bool {
+	return strings.HasPrefix(u.Flags, "G")
+}
+
+// Who asks the server about the users matching mask, or the members of a
+// channel, and updates the members we know about with the answer. WHOX is
+// used when the server supports it so we also learn accounts.
+func (c *Connection) Who(mask string) error {
+	return c.EnqueueCommand(c.whoCommand(mask))
+}
+
+// whoCommand returns a WHOX query for mask if the server supports it and a
+// plain WHO otherwise.
+func (c *Connection) whoCommand(mask string) Command {
+	if _, ok := c.Features().Tokens["WHOX"]; ok {
+		return NewWhoCommand(mask, whoxFields)
+	}
+
+	return NewWhoCommand(mask)
+}
+
+// updateMember copies what we learned about a user onto every channel member
+// entry they have. The caller must hold the connection's lock.
+func (c *Connection) updateMember(user WhoUser, hasAccount bool) {
+	c.forEachMember(user.Nick, func(nick *Nick) {
+		nick.Ident = user.Ident
+		nick.Host = user.Host
+		nick.Server = user.Server
+		nick.RealName = user.RealName
+		nick.Away = user.Away()
+
+		if hasAccount {
+			nick.Account = user.Account
+		}
+	})
+}
+
+// defaultWhoRequester asks who is in a channel after we join it.
+func defaultWhoRequester(ctx context.Context, c *Connection, command Command) error {
+	cmd, ok := command.(*JoinCommand)
+	if !ok || !c.isSelf(prefixNick(cmd.Message())) {
+		return nil
+	}
+
+	return c.enqueueRestoreCommand(c.whoCommand(cmd.Channel()))
+}
+
+// defaultWhoReplyHandler fills in channel members from WHO and WHOX replies.
+// Plain WHO doesn't include accounts, so those are left as they were.
+func defaultWhoReplyHandler(ctx context.Context, c *Connection, reply Reply) error {
+	switch r := reply.(type) {
+	case *WhoReply:
+		if err := r.Validate(); err != nil {
+			return err
+		}
+
+		c.WithWriteLock(ctx, func(conn *Connection) {
+			conn.updateMember(r.User(), false)
+		})
+	case *WhoxReply:
+		if err := r.Validate(); err != nil {
+			return err
+		}
+
+		user, ok := r.User()
+		if !ok {
+			return nil
+		}
+
+		c.WithWriteLock(ctx, func(conn *Connection) {
+			conn.updateMember(user, true)
+		})
+	case *EndOfWhoReply:
+		if err := r.Validate(); err != nil {
+			return err
+		}
+
+		c.log.Debug("end of WHO", logger.Param{Key: "mask", Value: r.Mask()})
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package irc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWho(t *testing.T) {
+	c := newTestConnection(t, Config{Channels: []string{"#tenyks"}})
+	c.Status.CurrentNick = "tenyks"
+
+	dispatch(t, c, ":tenyks!~tenyks@example.com JOIN #tenyks")
+	dispatch(t, c, ":irc.test 353 tenyks = #tenyks :tenyks @alice bob")
+	dispatch(t, c, ":irc.test 366 tenyks #tenyks :End of /NAMES list.")
+	drainCommands(t, c)
+
+	dispatch(t, c, ":irc.test 352 tenyks #tenyks ~alice alice.example.com irc.test alice H@ :0 Alice Liddell")
+	dispatch(t, c, ":irc.test 352 tenyks #tenyks bob bob.example.com irc.test bob G :3 Bob")
+	dispatch(t, c, ":irc.test 315 tenyks #tenyks :End of /WHO list.")
+
+	status := c.channels["#tenyks"].Status
+
+	require.Equal(t, "alice!~alice@alice.example.com", status.Nicks["alice"].Hostmask())
+	require.Equal(t, "Alice Liddell", status.Nicks["alice"].RealName)
+	require.Equal(t, "irc.test", status.Nicks["alice"].Server)
+	require.False(t, status.Nicks["alice"].Away)
+	require.True(t, status.Nicks["bob"].Away)
+
+	t.Run("whox", func(t *testing.T) {
+		dispatch(t, c, ":irc.test 005 tenyks WHOX :are supported by this server")
+
+		require.NoError(t, c.Who("#tenyks"))
+		require.Equal(t, []string{"WHO #tenyks %tcuhsnfar,152\r\n"}, drainCommands(t, c))
+
+		dispatch(t, c, ":irc.test 354 tenyks 152 #tenyks ~alice alice.example.com irc.test alice H@ alice :Alice Liddell")
+		dispatch(t, c, ":irc.test 354 tenyks 152 #tenyks bob bob.example.com irc.test bob H 0 :Bob")
+		// a reply to someone else's query with different fields
+		dispatch(t, c, ":irc.test 354 tenyks 7 bob")
+
+		require.Equal(t, "alice", status.Nicks["alice"].Account)
+		require.Empty(t, status.Nicks["bob"].Account)
+		require.False(t, status.Nicks["bob"].Away)
+	})
+
+	t.Run("names keeps details", func(t *testing.T) {
+		dispatch(t, c, ":irc.test 353 tenyks = #tenyks :tenyks alice +bob")
+		dispatch(t, c, ":irc.test 366 tenyks #tenyks :End of /NAMES list.")
+
+		require.Equal(t, "alice", status.Nicks["alice"].Account)
+		require.Empty(t, status.Nicks["alice"].Modes)
+		require.Equal(t, "v", status.Nicks["bob"].Modes)
+		require.Equal(t, "bob.example.com", status.Nicks["bob"].Host)
+	})
+
+	t.Run("join prefix", func(t *testing.T) {
+		dispatch(t, c, ":carol!~carol@carol.example.com JOIN #tenyks")
+
+		require.Equal(t, "carol!~carol@carol.example.com", status.Nicks["carol"].Hostmask())
+	})
+}
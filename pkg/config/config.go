@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"time"
 )
 
 type Unmarshaler interface {
@@ -72,10 +73,126 @@ type IRCServerConfig struct {
 	Nicks      []string `json:"nicks"`
 	User       string   `json:"user"`
 	RealName   string   `json:"real_name"`
-	Channels   []string `json:"channels"`
-	Commands   []string `json:"commands"`
-	UseTLS     bool     `json:"use_tls"`
-	RootCAPath string   `json:"root_ca"`
+	// Channels are either channel names or objects with a name, key and
+	// per channel options.
+	Channels     []ChannelConfig `json:"channels"`
+	Commands     []string        `json:"commands"`
+	Capabilities []string        `json:"capabilities"`
+	UseTLS       bool            `json:"use_tls"`
+	RootCAPath   string          `json:"root_ca"`
+	// ClientCert and ClientKey are paths to a PEM certificate and key used
+	// for CertFP and SASL EXTERNAL.
+	ClientCert            string `json:"client_cert"`
+	ClientKey             string `json:"client_key"`
+	TLSServerName         string `json:"tls_server_name"`
+	TLSInsecureSkipVerify bool   `json:"tls_insecure_skip_verify"`
+	// SASLMechanism is either PLAIN or EXTERNAL. EXTERNAL requires a client
+	// certificate.
+	SASLMechanism string `json:"sasl_mechanism"`
+	SASLAccount   string `json:"sasl_account"`
+	SASLPassword  string `json:"sasl_password"`
+	// SASLTimeout is how long to wait for SASL to finish before registering
+	// without an account.
+	SASLTimeout Duration `json:"sasl_timeout"`
+	// NickRegainInterval is how often to check if the first nick in Nicks is
+	// free again when we had to register with another one.
+	NickRegainInterval Duration `json:"nick_regain_interval"`
+	// PingInterval and PingTimeout control how often we ping the server and
+	// how long we wait for an answer before reconnecting.
+	PingInterval Duration `json:"ping_interval"`
+	PingTimeout  Duration `json:"ping_timeout"`
+	// FloodBurst and FloodRate configure outbound flood protection: how many
+	// lines can go out at once and how long it takes to earn one back. A
+	// negative rate turns flood protection off.
+	FloodBurst int      `json:"flood_burst"`
+	FloodRate  Duration `json:"flood_rate"`
+	// FloodPenaltyBytes charges an extra line for every this many bytes.
+	FloodPenaltyBytes int `json:"flood_penalty_bytes"`
+	// FloodPerTarget rate limits each channel or nick separately as well.
+	// FloodTargetBurst and FloodTargetRate are the limits for each target and
+	// default to half the burst and twice the rate.
+	FloodPerTarget   bool     `json:"flood_per_target"`
+	FloodTargetBurst int      `json:"flood_target_burst"`
+	FloodTargetRate  Duration `json:"flood_target_rate"`
+	// FloodMaxDelay drops messages that wait longer than this to be sent.
+	FloodMaxDelay Duration `json:"flood_max_delay"`
+	// RejoinPolicy is what to do after we're kicked from a channel or can't
+	// join it: never, delay or after-sasl. ChannelRejoinPolicies sets it for
+	// individual channels.
+	RejoinPolicy          string            `json:"rejoin_policy"`
+	ChannelRejoinPolicies map[string]string `json:"channel_rejoin_policies"`
+	// RejoinDelay and RejoinMaxDelay are the first and longest waits between
+	// attempts to get back into a channel.
+	RejoinDelay    Duration `json:"rejoin_delay"`
+	RejoinMaxDelay Duration `json:"rejoin_max_delay"`
+	// CTCPVersion is what we answer CTCP VERSION with and CTCPIgnore lists
+	// CTCP queries we don't answer. CTCPReplyRate limits how often we answer
+	// and a negative rate turns answers off.
+	CTCPVersion   string   `json:"ctcp_version"`
+	CTCPIgnore    []string `json:"ctcp_ignore"`
+	CTCPReplyRate Duration `json:"ctcp_reply_rate"`
+	// InviteAllowlist lists hostmasks, or $a:account entries, of users whose
+	// invites we accept. Invites are ignored when it's empty.
+	InviteAllowlist []string `json:"invite_allowlist"`
+}
+
+// ChannelConfig is a channel to join. It can be written as just the channel
+// name, like "#tenyks", or as an object:
+//
+//	{"name": "#ops", "key": "hunter2", "rejoin_policy": "never"}
+type ChannelConfig struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	// RejoinPolicy overrides the server's rejoin_policy for this channel.
+	RejoinPolicy string `json:"rejoin_policy"`
+}
+
+func (cc *ChannelConfig) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err == nil {
+		*cc = ChannelConfig{Name: name}
+
+		return nil
+	}
+
+	type channelConfig ChannelConfig
+
+	var c channelConfig
+	if err := json.Unmarshal(b, &c); err != nil {
+		return err
+	}
+
+	if c.Name == "" {
+		return fmt.Errorf("channel is missing a name")
+	}
+
+	*cc = ChannelConfig(c)
+
+	return nil
+}
+
+// Duration is a time.Duration that's written as a string like "30s" or "5m"
+// in configuration files.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	dur, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(dur)
+
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
 }
 
 type ServiceConfig struct {
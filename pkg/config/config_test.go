@@ -0,0 +1,58 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIRCServerConfig(t *testing.T) {
+	raw := `{
+		"logging": {"debug": true},
+		"servers": [{
+			"kind": "irc",
+			"name": "test",
+			"config": {
+				"server_addr": "irc.example.com:6697",
+				"nicks": ["tenyks"],
+				"channels": ["#tenyks", {"name": "#ops", "key": "hunter2"}],
+				"flood_burst": 6,
+				"flood_rate": "2s",
+				"flood_per_target": true,
+				"flood_target_burst": 2,
+				"flood_target_rate": "5s",
+				"sasl_timeout": "45s"
+			}
+		}]
+	}`
+
+	var cfg Config
+	require.NoError(t, json.Unmarshal([]byte(raw), &cfg))
+	require.Len(t, cfg.Servers, 1)
+
+	irc, ok := cfg.Servers[0].Config.(IRCServerConfig)
+	require.True(t, ok)
+
+	require.Equal(t, "test", irc.Name)
+	require.Equal(t, []ChannelConfig{{Name: "#tenyks"}, {Name: "#ops", Key: "hunter2"}}, irc.Channels)
+	require.Equal(t, 6, irc.FloodBurst)
+	require.Equal(t, Duration(time.Second*2), irc.FloodRate)
+	require.True(t, irc.FloodPerTarget)
+	require.Equal(t, 2, irc.FloodTargetBurst)
+	require.Equal(t, Duration(time.Second*5), irc.FloodTargetRate)
+	require.Equal(t, Duration(time.Second*45), irc.SASLTimeout)
+}
+
+func TestDuration(t *testing.T) {
+	var d Duration
+	require.NoError(t, json.Unmarshal([]byte(`"1m30s"`), &d))
+	require.Equal(t, Duration(time.Second*90), d)
+
+	require.Error(t, json.Unmarshal([]byte(`"soon"`), &d))
+
+	b, err := json.Marshal(d)
+	require.NoError(t, err)
+	require.Equal(t, `"1m30s"`, string(b))
+}
@@ -36,6 +36,34 @@ var chatMessageSchema = `
         "mention": {
             "type": "boolean",
             "description": "whether the message contains the connection nick in a channel message"
+        },
+        "action": {
+            "type": "boolean",
+            "description": "whether the message is an action, like /me on IRC"
+        },
+        "notice": {
+            "type": "boolean",
+            "description": "whether the message is a notice, which must never be answered automatically"
+        },
+        "account": {
+            "type": "string",
+            "description": "the services account the sender is logged into, if they are"
+        },
+        "messageId": {
+            "type": "string",
+            "description": "the id the chat network gave the message, if it has one"
+        },
+        "replyTo": {
+            "type": "string",
+            "description": "the id of the message this message replies or reacts to"
+        },
+        "reaction": {
+            "type": "string",
+            "description": "a reaction, usually an emoji, to the message in replyTo"
+        },
+        "requestId": {
+            "type": "string",
+            "description": "an id the sending service picks so it can match delivery events to the message"
         },
 		"content": {
 			"type": "string",
@@ -59,6 +87,36 @@ type ChatMessage struct {
 	Mention         bool      `json:"mention"`
 	Content         string    `json:"content"`
 	Timestamp       time.Time `json:"timestamp"`
+	// Action is true for messages that describe what the sender is doing,
+	// like "/me waves" on IRC. Content is just the action, "waves".
+	Action bool `json:"action,omitempty"`
+	// Notice is true for notices, like IRC NOTICEs. Bots must never reply to
+	// a notice automatically and should send their own replies as notices to
+	// avoid reply loops with other bots.
+	Notice bool `json:"notice,omitempty"`
+	// Account is the services account the sender is logged into. Unlike
+	// nicks, accounts can't be taken by someone else, so use this to decide
+	// who is allowed to do what. It's empty if the sender isn't logged in or
+	// the network can't tell us.
+	Account string `json:"account,omitempty"`
+	// MessageID is the id the chat network gave the message, like the IRCv3
+	// msgid tag. It stays the same when a message is played back again, by a
+	// bouncer or after a reconnect, so services can use it to skip messages
+	// they've already seen or to refer to a message later. It's empty if the
+	// network doesn't give messages ids.
+	MessageID string `json:"messageId,omitempty"`
+	// ReplyTo is the MessageID of the message this one replies to. Networks
+	// that can't thread replies get the reply addressed to the sender of the
+	// original message instead, like "alice: sure".
+	ReplyTo string `json:"replyTo,omitempty"`
+	// Reaction is a reaction, usually an emoji, to the message in ReplyTo.
+	// Content is ignored for reactions unless the network can't send them,
+	// in which case the reaction is sent as a reply.
+	Reaction string `json:"reaction,omitempty"`
+	// RequestID is picked by a service for a message it sends. Once the
+	// message is delivered or fails, a delivery event with the same
+	// requestId attribute is sent back.
+	RequestID string `json:"requestId,omitempty"`
 }
 
 func (cm *ChatMessage) Encode(w io.Writer) error {
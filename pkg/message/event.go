@@ -0,0 +1,98 @@
+package message
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var eventMessageSchema = `
+{
+	"$schema": "https://json-schema.org/draft/2019-09/schema#",
+    "$id": "https://tenyks.io/messages.event.schema.json",
+    "title": "Event message",
+	"description": "Tenyks event message schema",
+	"type": "object",
+	"required": [
+		"kind",
+		"targetPath",
+		"timestamp"
+	],
+	"properties": {
+		"kind": {
+			"type": "string",
+			"enum": ["topic", "invite", "delivery"],
+            "description": "what happened, such as topic"
+		},
+		"targetPath": {
+			"type": "string",
+            "description": "the path to the target the event happened in or to"
+		},
+		"originPath": {
+			"type": "string",
+            "description": "the path to the target that caused the event"
+		},
+		"content": {
+			"type": "string",
+            "description": "the text that goes with the event, such as the new topic"
+		},
+		"attributes": {
+			"type": "object",
+			"additionalProperties": {
+				"type": "string"
+			},
+            "description": "extra details that depend on the kind of event"
+		},
+		"timestamp": {
+			"type": "string",
+			"format": "date-time",
+            "description": "when the event happened"
+		}
+	},
+	"additionalProperties": false
+}`
+
+// EventKind is the kind of thing an EventMessage describes.
+type EventKind string
+
+const (
+	// EventKindTopic is sent when a channel's topic changes. Content is the
+	// new topic.
+	EventKindTopic EventKind = "topic"
+	// EventKindInvite is sent when someone invites us into a channel. The
+	// accepted attribute says whether we're joining it.
+	EventKindInvite EventKind = "invite"
+	// EventKindDelivery is sent for a chat message with a RequestID once it's
+	// delivered or failed. The requestId attribute matches the message,
+	// status says how sure we are it arrived, messageId is the id the network
+	// gave it, if any, and error says why it failed. It goes back to the
+	// service that sent the message, see WithDeliveryHandler.
+	EventKindDelivery EventKind = "delivery"
+)
+
+// EventMessage tells services about something that happened on a chat
+// network that isn't a chat message, like a channel topic changing.
+type EventMessage struct {
+	Kind       EventKind         `json:"kind"`
+	TargetPath string            `json:"targetPath"`
+	OriginPath string            `json:"originPath,omitempty"`
+	Content    string            `json:"content,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Timestamp  time.Time         `json:"timestamp"`
+}
+
+func (em *EventMessage) Encode(w io.Writer) error {
+	return json.NewEncoder(w).Encode(em)
+}
+
+func (em *EventMessage) Decode(r io.Reader) error {
+	return json.NewDecoder(r).Decode(em)
+}
+
+func (em *EventMessage) Validator() Validator {
+	return &JSONSchemaValidator{
+		SchemaLoader: gojsonschema.NewStringLoader(eventMessageSchema),
+	}
+}
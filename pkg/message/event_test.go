@@ -0,0 +1,74 @@
+package message
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	validEventMessage = `
+{
+    "kind": "topic",
+    "targetPath": "/irc/freenode/#tenyks",
+    "originPath": "/irc/freenode/mr-user-person",
+    "content": "welcome to tenyks",
+    "attributes": {"setBy": "mr-user-person!~user@example.com"},
+    "timestamp": "2020-08-21T03:23:30-07:00"
+}`
+	invalidEventMessage = `
+{
+    "kind": "topic",
+    "content": "welcome to tenyks",
+    "timestamp": "2020-08-21T03:23:30-07:00"
+}`
+	unknownKindEventMessage = `
+{
+    "kind": "kick",
+    "targetPath": "/irc/freenode/#tenyks",
+    "timestamp": "2020-08-21T03:23:30-07:00"
+}`
+)
+
+func TestEventMessageValidation(t *testing.T) {
+	msg := EventMessage{}
+	buf := bytes.NewBufferString(validEventMessage)
+
+	require.NoError(t, msg.Validator().Validate(buf.Bytes()))
+	require.NoError(t, msg.Decode(buf))
+
+	expectedTime, err := time.Parse(time.RFC3339, "2020-08-21T03:23:30-07:00")
+	require.NoError(t, err)
+
+	require.Equal(t, EventKindTopic, msg.Kind)
+	require.Equal(t, "/irc/freenode/#tenyks", msg.TargetPath)
+	require.Equal(t, "/irc/freenode/mr-user-person", msg.OriginPath)
+	require.Equal(t, "welcome to tenyks", msg.Content)
+	require.Equal(t, "mr-user-person!~user@example.com", msg.Attributes["setBy"])
+	require.Equal(t, expectedTime, msg.Timestamp)
+
+	{
+		msg := EventMessage{}
+		buf := bytes.NewBufferString(invalidEventMessage)
+
+		require.Error(t, msg.Validator().Validate(buf.Bytes()))
+	}
+
+	{
+		msg := EventMessage{}
+		buf := bytes.NewBufferString(unknownKindEventMessage)
+
+		require.Error(t, msg.Validator().Validate(buf.Bytes()))
+	}
+}
+
+func TestEventMessageEnvelope(t *testing.T) {
+	env := NewMessageEnvelope(&EventMessage{Kind: EventKindTopic})
+
+	b, err := json.Marshal(env)
+	require.NoError(t, err)
+	require.Contains(t, string(b), `"type":"event"`)
+}
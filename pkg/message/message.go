@@ -7,6 +7,7 @@ type MessageType string
 const (
 	MessageTypeChat    MessageType = "chat"
 	MessageTypeControl MessageType = "control"
+	MessageTypeEvent   MessageType = "event"
 )
 
 // Message can encode, decode and validate messages flowing through tenkys
@@ -1,7 +1,26 @@
 package message
 
+import "context"
+
 type Registry interface {
 	RegisterMessageHandler(HandlerFunc)
 }
 
 type HandlerFunc func(Message)
+
+type deliveryHandlerKey struct{}
+
+// WithDeliveryHandler returns a context that makes an adapter's SendAsync
+// send the delivery events for a message to h instead of every registered
+// handler, so they go back to the service that sent the message. h must not
+// block for long: adapters may drop events for a handler that falls behind.
+func WithDeliveryHandler(ctx context.Context, h HandlerFunc) context.Context {
+	return context.WithValue(ctx, deliveryHandlerKey{}, h)
+}
+
+// DeliveryHandler returns the handler set with WithDeliveryHandler, if any.
+func DeliveryHandler(ctx context.Context) (HandlerFunc, bool) {
+	h, ok := ctx.Value(deliveryHandlerKey{}).(HandlerFunc)
+
+	return h, ok && h != nil
+}
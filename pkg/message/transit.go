@@ -8,8 +8,11 @@ type MessageEnvelope struct {
 func NewMessageEnvelope(msg Message) *MessageEnvelope {
 	mt := MessageTypeChat
 
-	if _, ok := msg.(*ControlMessage); ok {
+	switch msg.(type) {
+	case *ControlMessage:
 		mt = MessageTypeControl
+	case *EventMessage:
+		mt = MessageTypeEvent
 	}
 
 	return &MessageEnvelope{
@@ -28,6 +28,8 @@ func (ws *WebsocketServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer conn.Close()
 
 	readCh := make(chan *message.ChatMessage)
+	// deliveries are the delivery events for messages this service sent
+	deliveries := make(chan message.Message, 10)
 	go func() {
 		for {
 			msg := &message.ChatMessage{}
@@ -47,10 +49,21 @@ func (ws *WebsocketServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			if err := conn.WriteJSON(msg); err != nil {
 				log.Error(err)
 			}
+		case msg := <-deliveries:
+			if err := conn.WriteJSON(msg); err != nil {
+				log.Error(err)
+			}
 		case msg := <-readCh:
+			ctx := message.WithDeliveryHandler(r.Context(), func(msg message.Message) {
+				select {
+				case deliveries <- msg:
+				case <-r.Context().Done():
+				}
+			})
+
 			// TODO find path
 			for _, adapters := range ws.baseRegistry.GetAdaptersFor {
-				c.SendAsync(r.Context(), msg)
+				c.SendAsync(ctx, msg)
 			}
 		case <-r.Context().Done():
 			break